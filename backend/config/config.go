@@ -1,48 +1,131 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+
+	"ziggler_backend/logging"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	Port               string
+	DBDriver           string
+	DatabaseURL        string
 	DBPath             string
+	DBMaxOpenConns     int
+	DBMaxIdleConns     int
+	DBConnMaxLifetime  int
+	AutoMigrate        bool
+	SeedSampleData     bool
 	JWTSecret          string
 	AppEnv             string
 	GinMode            string
 	CorsAllowedOrigins string
 	CorsAllowCreds     string
+	PubSubDriver       string
+	RedisURL           string
+	SMTPHost           string
+	SMTPPort           string
+	SMTPUsername       string
+	SMTPPassword       string
+	SMTPFrom           string
+	AppBaseURL         string
+	DigestHour         int
+	WorkloadThreshold  int
+	LogLevel           string
+	LogFormat          string
+	OTelExporter       string
+	OTelEndpoint       string
+	OTelInsecure       bool
+	OTelServiceName    string
+	ShutdownTimeout    int
 }
 
 var AppConfig *Config
 
 func LoadConfig() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
-	}
+	envErr := godotenv.Load()
 
 	AppConfig = &Config{
 		Port:               getEnv("PORT", "8080"),
+		DBDriver:           getEnv("DB_DRIVER", "sqlite"),
+		DatabaseURL:        getEnv("DATABASE_URL", ""),
 		DBPath:             getEnv("DB_PATH", "ziggler.db"),
+		DBMaxOpenConns:     getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:     getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime:  getEnvInt("DB_CONN_MAX_LIFETIME", 1800),
+		AutoMigrate:        getEnvBool("AUTO_MIGRATE", true),
+		SeedSampleData:     getEnvBool("SEED_SAMPLE_DATA", false),
 		JWTSecret:          getEnv("JWT_SECRET", ""),
 		AppEnv:             getEnv("APP_ENV", "development"),
 		GinMode:            getEnv("GIN_MODE", "debug"),
 		CorsAllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", "*"),
 		CorsAllowCreds:     getEnv("CORS_ALLOW_CREDENTIALS", "true"),
+		PubSubDriver:       getEnv("PUBSUB_DRIVER", "memory"),
+		RedisURL:           getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		SMTPHost:           getEnv("SMTP_HOST", ""),
+		SMTPPort:           getEnv("SMTP_PORT", "587"),
+		SMTPUsername:       getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:       getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:           getEnv("SMTP_FROM", "Ziggler <no-reply@ziggler.local>"),
+		AppBaseURL:         getEnv("APP_BASE_URL", "http://localhost:8080"),
+		DigestHour:         getEnvInt("DIGEST_HOUR", 8),
+		WorkloadThreshold:  getEnvInt("WORKLOAD_THRESHOLD", 5),
+		LogLevel:           getEnv("LOG_LEVEL", "info"),
+		LogFormat:          getEnv("LOG_FORMAT", "json"),
+		OTelExporter:       getEnv("OTEL_TRACES_EXPORTER", "none"),
+		OTelEndpoint:       getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTelInsecure:       getEnvBool("OTEL_EXPORTER_OTLP_INSECURE", false),
+		OTelServiceName:    getEnv("OTEL_SERVICE_NAME", "ziggler-backend"),
+		ShutdownTimeout:    getEnvInt("SHUTDOWN_TIMEOUT", 15),
+	}
+
+	if err := logging.Init(AppConfig.LogLevel, AppConfig.LogFormat); err != nil {
+		logging.Fatal("Invalid logging configuration", "error", err)
+	}
+
+	if envErr != nil {
+		slog.Info("No .env file found, using system environment variables")
+	}
+
+	switch AppConfig.DBDriver {
+	case "sqlite":
+	case "postgres", "mysql":
+		if AppConfig.DatabaseURL == "" {
+			logging.Fatal("DATABASE_URL is required for the database driver", "driver", AppConfig.DBDriver)
+		}
+	default:
+		logging.Fatal("DB_DRIVER must be sqlite, postgres or mysql", "driver", AppConfig.DBDriver)
+	}
+
+	if AppConfig.DBMaxOpenConns < 0 || AppConfig.DBMaxIdleConns < 0 || AppConfig.DBConnMaxLifetime < 0 {
+		logging.Fatal("DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME must not be negative")
+	}
+
+	if AppConfig.DigestHour < 0 || AppConfig.DigestHour > 23 {
+		logging.Fatal("DIGEST_HOUR must be between 0 and 23")
+	}
+
+	if AppConfig.WorkloadThreshold < 1 {
+		logging.Fatal("WORKLOAD_THRESHOLD must be at least 1")
+	}
+
+	if AppConfig.ShutdownTimeout < 1 {
+		logging.Fatal("SHUTDOWN_TIMEOUT must be at least 1 second")
 	}
 
 	if AppConfig.JWTSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
+		logging.Fatal("JWT_SECRET environment variable is required")
 	}
 
 	if len(AppConfig.JWTSecret) < 32 {
-		log.Fatal("JWT_SECRET must be at least 32 characters long for security")
+		logging.Fatal("JWT_SECRET must be at least 32 characters long for security")
 	}
 
-	log.Printf("Configuration loaded: App Environment: %s, Port: %s", AppConfig.AppEnv, AppConfig.Port)
+	slog.Info("Configuration loaded", "app_env", AppConfig.AppEnv, "port", AppConfig.Port, "db_driver", AppConfig.DBDriver)
 }
 
 func getEnv(key, fallback string) string {
@@ -52,6 +135,30 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logging.Fatal("Environment variable must be an integer", "key", key, "value", value)
+	}
+	return parsed
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logging.Fatal("Environment variable must be a boolean", "key", key, "value", value)
+	}
+	return parsed
+}
+
 func GetJWTSecret() []byte {
 	return []byte(AppConfig.JWTSecret)
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"ziggler_backend/config"
+	"ziggler_backend/database"
+)
+
+// runSeed runs the seed command and returns the exit code. Seeding is
+// skipped if the database already has users.
+func runSeed(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: ziggler_backend seed")
+		return 2
+	}
+
+	config.LoadConfig()
+	database.InitDB()
+	defer database.Close()
+
+	database.SeedDatabase()
+	return 0
+}
+
+// runExport runs the export command and returns the exit code.
+func runExport(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ziggler_backend export <file>")
+		return 2
+	}
+
+	config.LoadConfig()
+	database.InitDB()
+	defer database.Close()
+
+	// Write to a temporary file so a failed export never leaves a
+	// truncated dump behind.
+	tmp := args[0] + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 1
+	}
+	dump, err := database.Export(database.DB, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, args[0])
+	}
+	if err != nil {
+		os.Remove(tmp)
+		fmt.Fprintln(os.Stderr, "export:", err)
+		return 1
+	}
+
+	printTables("exported %d rows from %s\n", dump)
+	return 0
+}
+
+// runImport runs the import command and returns the exit code.
+func runImport(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ziggler_backend import <file>")
+		return 2
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+	defer f.Close()
+
+	config.LoadConfig()
+	database.InitDB()
+	defer database.Close()
+
+	dump, err := database.Import(database.DB, f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+
+	printTables("imported %d rows into %s\n", dump)
+	return 0
+}
+
+func printTables(format string, dump *database.Dump) {
+	for _, table := range dump.Tables {
+		fmt.Printf(format, len(table.Rows), table.Name)
+	}
+}
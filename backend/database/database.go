@@ -1,27 +1,68 @@
 package database
 
 import (
-	"log"
+	"log/slog"
 
 	"ziggler_backend/config"
+	"ziggler_backend/logging"
+	"ziggler_backend/metrics"
+	"ziggler_backend/tracing"
 
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// InitDB connects to the database and, unless AUTO_MIGRATE is off, brings
+// its schema up to date.
 func InitDB() {
-	var err error
-	DB, err = gorm.Open(sqlite.Open(config.AppConfig.DBPath), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	Connect()
+
+	if config.AppConfig.AutoMigrate {
+		if _, err := MigrateUp(DB); err != nil {
+			logging.Fatal("Failed to migrate database", "error", err)
+		}
+	} else if pending, err := PendingMigrations(DB); err != nil {
+		logging.Fatal("Failed to read migration status", "error", err)
+	} else if len(pending) > 0 {
+		slog.Warn("Database schema is behind, run the migrate command", "pending", len(pending))
 	}
 
-	err = DB.AutoMigrate(&User{}, &Task{})
+	slog.Info("Database initialized successfully", "driver", DB.Dialector.Name())
+}
+
+// Connect opens the configured database without touching its schema.
+func Connect() {
+	dialect, err := dialector(config.AppConfig)
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		logging.Fatal("Invalid database configuration", "error", err)
+	}
+	DB, err = gorm.Open(dialect, &gorm.Config{Logger: newLogger()})
+	if err != nil {
+		logging.Fatal("Failed to connect to database", "driver", config.AppConfig.DBDriver, "error", err)
+	}
+	if err := configurePool(DB, config.AppConfig); err != nil {
+		logging.Fatal("Failed to configure database connection pool", "error", err)
 	}
 
-	log.Println("Database initialized successfully")
+	if err := metrics.InstrumentDB(DB); err != nil {
+		logging.Fatal("Failed to instrument database", "error", err)
+	}
+	if err := tracing.InstrumentDB(DB); err != nil {
+		logging.Fatal("Failed to instrument database", "error", err)
+	}
+
+	// Watchers use an explicit join model so the rows carry a timestamp.
+	if err := DB.SetupJoinTable(&Task{}, "Watchers", &TaskWatcher{}); err != nil {
+		logging.Fatal("Failed to set up task watchers", "error", err)
+	}
+}
+
+// Close closes the database's connection pool.
+func Close() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"ziggler_backend/config"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Supported values of DB_DRIVER.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// dialector returns the GORM dialector for the configured driver. SQLite
+// uses DATABASE_URL if set and DB_PATH otherwise.
+func dialector(cfg *config.Config) (gorm.Dialector, error) {
+	switch cfg.DBDriver {
+	case DriverSQLite:
+		dsn := cfg.DatabaseURL
+		if dsn == "" {
+			dsn = cfg.DBPath
+		}
+		return sqlite.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(cfg.DatabaseURL), nil
+	case DriverMySQL:
+		dsn, err := mysqlDSN(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.DBDriver)
+	}
+}
+
+// mysqlDSN accepts either a mysql:// URL or a Go MySQL driver DSN
+// (user:pass@tcp(host:3306)/db) and returns a DSN that scans DATETIME
+// columns into time.Time and stores them as UTC.
+func mysqlDSN(raw string) (string, error) {
+	var cfg *mysqldriver.Config
+	if strings.HasPrefix(raw, "mysql://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+		cfg = mysqldriver.NewConfig()
+		cfg.User = u.User.Username()
+		cfg.Passwd, _ = u.User.Password()
+		cfg.Net = "tcp"
+		cfg.Addr = u.Host
+		if u.Port() == "" {
+			cfg.Addr += ":3306"
+		}
+		cfg.DBName = strings.TrimPrefix(u.Path, "/")
+		for key, values := range u.Query() {
+			if cfg.Params == nil {
+				cfg.Params = make(map[string]string)
+			}
+			cfg.Params[key] = values[len(values)-1]
+		}
+	} else {
+		var err error
+		cfg, err = mysqldriver.ParseDSN(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+	}
+
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	return cfg.FormatDSN(), nil
+}
+
+// configurePool applies the connection pool settings. Zero leaves a setting
+// unlimited.
+func configurePool(db *gorm.DB, cfg *config.Config) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DBConnMaxLifetime) * time.Second)
+	return nil
+}
+
+// SQLiteFile returns the path of the SQLite database file, or "" when the
+// database is not SQLite or not a plain file (in-memory or a file: URI).
+func SQLiteFile() string {
+	cfg := config.AppConfig
+	if cfg.DBDriver != DriverSQLite {
+		return ""
+	}
+	path := cfg.DatabaseURL
+	if path == "" {
+		path = cfg.DBPath
+	}
+	if path == ":memory:" || strings.HasPrefix(path, "file:") {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,261 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// exportModels are the tables Export writes, in an order that satisfies
+// foreign keys on import. The event log, the outbox and the email queue
+// only hold delivery state, so they are left out.
+var exportModels = []interface{}{
+	&User{},
+	&Task{},
+	&TaskWatcher{},
+	&TaskStatusChange{},
+	&NotificationPreference{},
+	&Notification{},
+	&Mention{},
+}
+
+// Dump is the portable form of the database written by Export. Rows are
+// keyed by column name, so a dump taken from one driver loads into another.
+type Dump struct {
+	SchemaVersion uint        `json:"schema_version"`
+	ExportedAt    time.Time   `json:"exported_at"`
+	Tables        []DumpTable `json:"tables"`
+}
+
+type DumpTable struct {
+	Name string                   `json:"name"`
+	Rows []map[string]interface{} `json:"rows"`
+}
+
+// Export writes every exported table, including soft-deleted rows, to w as
+// JSON.
+func Export(db *gorm.DB, w io.Writer) (*Dump, error) {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &Dump{SchemaVersion: version, ExportedAt: time.Now().UTC()}
+	for _, model := range exportModels {
+		s, err := parseModel(db, model)
+		if err != nil {
+			return nil, err
+		}
+
+		rows := []map[string]interface{}{}
+		if err := db.Table(s.Table).Order(strings.Join(s.PrimaryFieldDBNames, ", ")).Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("export %s: %w", s.Table, err)
+		}
+		for _, row := range rows {
+			for column, value := range row {
+				if field := s.LookUpField(column); field != nil {
+					row[column] = exportValue(field, value)
+				}
+			}
+		}
+		dump.Tables = append(dump.Tables, DumpTable{Name: s.Table, Rows: rows})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(dump); err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+// Import loads a dump written by Export into an empty database at the same
+// schema version, in a single transaction.
+func Import(db *gorm.DB, r io.Reader) (*Dump, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var dump Dump
+	if err := decoder.Decode(&dump); err != nil {
+		return nil, fmt.Errorf("read dump: %w", err)
+	}
+
+	version, err := schemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if dump.SchemaVersion != version {
+		return nil, fmt.Errorf("dump is at schema version %d but the database is at %d", dump.SchemaVersion, version)
+	}
+
+	tables := make([]*schema.Schema, 0, len(exportModels))
+	schemas := make(map[string]*schema.Schema, len(exportModels))
+	for _, model := range exportModels {
+		s, err := parseModel(db, model)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, s)
+		schemas[s.Table] = s
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, s := range tables {
+			var count int64
+			if err := tx.Table(s.Table).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("database is not empty: %s has %d rows", s.Table, count)
+			}
+		}
+
+		for _, table := range dump.Tables {
+			s, ok := schemas[table.Name]
+			if !ok {
+				return fmt.Errorf("unknown table %q", table.Name)
+			}
+			if err := importTable(tx, s, table.Rows); err != nil {
+				return fmt.Errorf("import %s: %w", table.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dump, nil
+}
+
+func importTable(tx *gorm.DB, s *schema.Schema, rows []map[string]interface{}) error {
+	// A task may have been moved under one created after it, so parents are
+	// set once every task exists.
+	parents := make(map[interface{}]interface{})
+
+	for _, row := range rows {
+		for column, value := range row {
+			field := s.LookUpField(column)
+			if field == nil || field.DBName == "" {
+				return fmt.Errorf("unknown column %q", column)
+			}
+			converted, err := importValue(field, value)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", column, err)
+			}
+			row[column] = converted
+		}
+		if s.Table == "tasks" && row["parent_id"] != nil {
+			parents[row["id"]] = row["parent_id"]
+			row["parent_id"] = nil
+		}
+	}
+
+	if len(rows) > 0 {
+		if err := tx.Table(s.Table).CreateInBatches(rows, 200).Error; err != nil {
+			return err
+		}
+	}
+	for id, parentID := range parents {
+		if err := tx.Table(s.Table).Where("id = ?", id).Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Postgres sequences do not advance for explicit IDs.
+	if field := s.PrioritizedPrimaryField; tx.Dialector.Name() == DriverPostgres && field != nil && field.AutoIncrement {
+		sql := fmt.Sprintf("SELECT setval(pg_get_serial_sequence(?, ?), (SELECT COALESCE(MAX(%s), 0) + 1 FROM %s), false)",
+			tx.Statement.Quote(field.DBName), tx.Statement.Quote(s.Table))
+		if err := tx.Exec(sql, s.Table, field.DBName).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportValue evens out how drivers scan columns: SQLite and MySQL return
+// booleans as numbers and MySQL returns text as bytes.
+func exportValue(field *schema.Field, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case int64:
+		if field.DataType == schema.Bool {
+			return v != 0
+		}
+	case float64:
+		if field.DataType == schema.Bool {
+			return v != 0
+		}
+	case time.Time:
+		return v.UTC()
+	}
+	return value
+}
+
+// importValue converts a decoded JSON value to the type of field.
+func importValue(field *schema.Field, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch field.DataType {
+	case schema.Bool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case json.Number:
+			return v.String() != "0", nil
+		}
+	case schema.Int:
+		if v, ok := value.(json.Number); ok {
+			return v.Int64()
+		}
+	case schema.Uint:
+		if v, ok := value.(json.Number); ok {
+			return strconv.ParseUint(v.String(), 10, 64)
+		}
+	case schema.Float:
+		if v, ok := value.(json.Number); ok {
+			return v.Float64()
+		}
+	case schema.String:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case schema.Time:
+		if v, ok := value.(string); ok {
+			return time.Parse(time.RFC3339Nano, v)
+		}
+	}
+	return nil, fmt.Errorf("unexpected value %v", value)
+}
+
+func parseModel(db *gorm.DB, model interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// schemaVersion returns the latest applied migration, failing if any are
+// pending, so dumps are only taken from and loaded into current schemas.
+func schemaVersion(db *gorm.DB) (uint, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return 0, err
+	}
+	var version uint
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return 0, fmt.Errorf("migration %04d_%s is pending", status.Version, status.Name)
+		}
+		version = status.Version
+	}
+	return version, nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Statements slower than this are logged as warnings.
+const slowQueryThreshold = 200 * time.Millisecond
+
+// slogLogger sends GORM's logs to slog with the caller's context, so failed
+// queries made on behalf of a request carry its request ID.
+type slogLogger struct {
+	level logger.LogLevel
+}
+
+func newLogger() logger.Interface {
+	return slogLogger{level: logger.Warn}
+}
+
+func (l slogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return slogLogger{level: level}
+}
+
+func (l slogLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l slogLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l slogLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace logs failed and slow statements, and every statement at debug
+// level. Missing records are expected and not treated as failures.
+func (l slogLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := elapsed > slowQueryThreshold
+
+	switch {
+	case failed && l.level >= logger.Error:
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "Database query failed", "error", err, "sql", sql, "rows", rows, "duration_ms", durationMillis(elapsed))
+	case slow && l.level >= logger.Warn:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "Slow database query", "sql", sql, "rows", rows, "duration_ms", durationMillis(elapsed))
+	case slog.Default().Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		slog.DebugContext(ctx, "Database query", "sql", sql, "rows", rows, "duration_ms", durationMillis(elapsed))
+	}
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
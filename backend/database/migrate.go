@@ -0,0 +1,448 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Migrations live in migrations/<driver>/ as NNNN_name.up.sql and
+// NNNN_name.down.sql. Statements are separated by a semicolon at the end of
+// a line. Each migration runs in a transaction; MySQL commits DDL
+// implicitly, so a failed MySQL migration may need manual cleanup.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+const (
+	// How long to wait for another process to finish migrating.
+	migrationLockTimeout = 2 * time.Minute
+
+	// A lock older than this is assumed to belong to a process that died
+	// while migrating.
+	migrationLockStale = 15 * time.Minute
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint
+	Name    string
+
+	up   string
+	down string
+}
+
+// SchemaMigration records an applied migration.
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// schemaMigrationLock holds at most one row, inserted by the process that is
+// migrating. The primary key makes a second insert fail on every database.
+type schemaMigrationLock struct {
+	ID       uint      `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"size:255"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigrationLock) TableName() string {
+	return "schema_migrations_lock"
+}
+
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrations returns the embedded migrations for db's driver in version
+// order.
+func Migrations(db *gorm.DB) ([]Migration, error) {
+	dir := path.Join("migrations", db.Dialector.Name())
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %s", db.Dialector.Name())
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file %s in %s", entry.Name(), dir)
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[uint(version)]
+		if migration == nil {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrateUp applies every pending migration in order and returns the ones
+// it applied. Concurrent callers, such as replicas starting together, wait
+// for each other.
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	migrations, err := Migrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := lockMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Databases created before versioned migrations already have the
+	// baseline schema, give or take columns AutoMigrate would have added.
+	if len(applied) == 0 && db.Migrator().HasTable(&User{}) {
+		if err := adoptLegacySchema(db, migrations[0]); err != nil {
+			return nil, fmt.Errorf("adopting existing schema: %w", err)
+		}
+		applied[migrations[0].Version] = SchemaMigration{Version: migrations[0].Version}
+	}
+
+	var ran []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := runMigration(db, migration.up, func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %s: %w", migration, err)
+		}
+		slog.Info("Applied migration", "migration", migration.String())
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, and
+// returns the ones it reverted.
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations(db)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[uint]Migration, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = migration
+	}
+
+	unlock, err := lockMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var applied []SchemaMigration
+	if err := db.Order("version desc").Limit(steps).Find(&applied).Error; err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for _, record := range applied {
+		migration, ok := known[record.Version]
+		if !ok {
+			return reverted, fmt.Errorf("applied migration %04d_%s is not known to this build", record.Version, record.Name)
+		}
+		err := runMigration(db, migration.down, func(tx *gorm.DB) error {
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("reverting migration %s: %w", migration, err)
+		}
+		slog.Info("Reverted migration", "migration", migration.String())
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// MigrationStatuses lists every known migration with when it was applied,
+// followed by any applied migration this build does not know about.
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations(db)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name, AppliedAt: &record.AppliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// PendingMigrations returns the known migrations not yet applied.
+func PendingMigrations(db *gorm.DB) ([]MigrationStatus, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []MigrationStatus
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status)
+		}
+	}
+	return pending, nil
+}
+
+// appliedMigrations reads schema_migrations, which is empty until the first
+// migration runs.
+func appliedMigrations(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	applied := make(map[uint]SchemaMigration)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func runMigration(db *gorm.DB, script string, record func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
+
+// splitStatements splits a migration script into statements, dropping
+// comment lines.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// lockMigrations creates the bookkeeping tables if needed and takes the
+// migration lock, waiting up to migrationLockTimeout for another process to
+// release it. The returned function releases it.
+func lockMigrations(db *gorm.DB) (func(), error) {
+	for _, table := range []interface{}{&SchemaMigration{}, &schemaMigrationLock{}} {
+		if db.Migrator().HasTable(table) {
+			continue
+		}
+		// Another process may create it at the same time.
+		if err := db.Migrator().CreateTable(table); err != nil && !db.Migrator().HasTable(table) {
+			return nil, err
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+
+	// Failed attempts are expected while another process holds the lock.
+	quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+
+	deadline := time.Now().Add(migrationLockTimeout)
+	for {
+		quiet.Where("locked_at < ?", time.Now().Add(-migrationLockStale)).Delete(&schemaMigrationLock{})
+
+		err := quiet.Create(&schemaMigrationLock{ID: 1, Owner: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			return func() {
+				db.Where("id = ? AND owner = ?", 1, owner).Delete(&schemaMigrationLock{})
+			}, nil
+		}
+
+		if time.Now().After(deadline) {
+			var holder schemaMigrationLock
+			if quiet.First(&holder, 1).Error != nil {
+				return nil, fmt.Errorf("taking the migration lock: %w", err)
+			}
+			return nil, fmt.Errorf("timed out waiting for the migration lock held by %s since %s", holder.Owner, holder.LockedAt.Format(time.RFC3339))
+		}
+		slog.Info("Waiting for another process to finish migrating")
+		time.Sleep(time.Second)
+	}
+}
+
+// legacyModels are the tables AutoMigrate used to manage, as they were at
+// the baseline. Columns added by later migrations must not appear here, or
+// adopting a legacy schema would add them before their migration runs.
+var legacyModels = []interface{}{&User{}, &Task{}, &Event{}, &baselineOutboxEvent{}, &Notification{}, &baselineNotificationPreference{}, &Mention{}, &TaskWatcher{}, &TaskStatusChange{}}
+
+// baselineOutboxEvent is OutboxEvent before 0002_outbox_dispatch.
+type baselineOutboxEvent struct {
+	ID          uint   `gorm:"primaryKey"`
+	Type        string `gorm:"not null"`
+	Topics      string
+	Payload     string
+	TraceParent string
+	Attempts    int
+	LastError   string
+	LockedUntil *time.Time
+	DeliveredAt *time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+func (baselineOutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// baselineNotificationPreference is NotificationPreference before
+// 0003_digest_claim.
+type baselineNotificationPreference struct {
+	UserID           uint   `gorm:"primaryKey;autoIncrement:false"`
+	EmailAssignments bool   `gorm:"default:true"`
+	EmailDigest      bool   `gorm:"default:false"`
+	UnsubscribeToken string `gorm:"size:64;uniqueIndex;not null"`
+	LastDigestAt     *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (baselineNotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// adoptLegacySchema brings a database created by AutoMigrate up to the
+// baseline one last time, backfilling the data that columns and tables added
+// since then expect, and records the baseline as applied.
+func adoptLegacySchema(db *gorm.DB, baseline Migration) error {
+	slog.Info("Adopting schema created before versioned migrations", "baseline", baseline.String())
+
+	backfillWatchers := !db.Migrator().HasTable(&TaskWatcher{})
+	backfillStatusHistory := !db.Migrator().HasTable(&TaskStatusChange{})
+	backfillStatusTimes := !db.Migrator().HasColumn(&Task{}, "CompletedAt")
+
+	if err := db.AutoMigrate(legacyModels...); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Tasks that predate watchers are watched by their creator and
+		// assignee, as new tasks are.
+		if backfillWatchers {
+			err := tx.Exec(`INSERT INTO task_watchers (task_id, user_id, created_at)
+				SELECT id, creator_id, CURRENT_TIMESTAMP FROM tasks
+				UNION
+				SELECT id, assignee_id, CURRENT_TIMESTAMP FROM tasks WHERE assignee_id IS NOT NULL`).Error
+			if err != nil {
+				return fmt.Errorf("backfilling task watchers: %w", err)
+			}
+		}
+
+		// Tasks that predate the status history get an approximate one:
+		// created as todo, then moved to their current status when last
+		// updated.
+		if backfillStatusHistory {
+			err := tx.Exec(`INSERT INTO task_status_changes (task_id, from_status, to_status, changed_at)
+				SELECT id, '', ?, created_at FROM tasks
+				UNION ALL
+				SELECT id, ?, status, updated_at FROM tasks WHERE status <> ?`,
+				TaskStatusTodo, TaskStatusTodo, TaskStatusTodo).Error
+			if err != nil {
+				return fmt.Errorf("backfilling task status history: %w", err)
+			}
+		}
+
+		// StartedAt and CompletedAt are when a task first entered
+		// in_progress and done; existing tasks take them from the status
+		// history.
+		if backfillStatusTimes {
+			err := tx.Exec(`UPDATE tasks SET
+				started_at = (SELECT MIN(changed_at) FROM task_status_changes WHERE task_id = tasks.id AND to_status = ?),
+				completed_at = (SELECT MIN(changed_at) FROM task_status_changes WHERE task_id = tasks.id AND to_status = ?)`,
+				TaskStatusInProgress, TaskStatusDone).Error
+			if err != nil {
+				return fmt.Errorf("backfilling task start and completion times: %w", err)
+			}
+		}
+
+		return tx.Create(&SchemaMigration{Version: baseline.Version, Name: baseline.Name, AppliedAt: time.Now()}).Error
+	})
+}
@@ -0,0 +1,127 @@
+-- Baseline: the schema as created by AutoMigrate before versioned migrations.
+
+CREATE TABLE `users` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `username` varchar(255) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `password` longtext NOT NULL,
+  `role` varchar(191) DEFAULT 'user',
+  `display_name` longtext,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_users_username` (`username`),
+  UNIQUE INDEX `idx_users_email` (`email`),
+  INDEX `idx_users_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE `tasks` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `parent_id` bigint unsigned,
+  `title` longtext NOT NULL,
+  `description` longtext,
+  `creator_id` bigint unsigned NOT NULL,
+  `assignee_id` bigint unsigned,
+  `status` varchar(191) DEFAULT 'todo',
+  `due_date` datetime(3) NULL,
+  `started_at` datetime(3) NULL,
+  `completed_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_tasks_due_date` (`due_date`),
+  INDEX `idx_tasks_completed_at` (`completed_at`),
+  INDEX `idx_tasks_deleted_at` (`deleted_at`),
+  CONSTRAINT `fk_tasks_subtasks` FOREIGN KEY (`parent_id`) REFERENCES `tasks`(`id`),
+  CONSTRAINT `fk_users_created_tasks` FOREIGN KEY (`creator_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_users_assigned_tasks` FOREIGN KEY (`assignee_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `task_watchers` (
+  `task_id` bigint unsigned,
+  `user_id` bigint unsigned,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`task_id`,`user_id`),
+  INDEX `idx_task_watchers_user_id` (`user_id`),
+  CONSTRAINT `fk_task_watchers_task` FOREIGN KEY (`task_id`) REFERENCES `tasks`(`id`),
+  CONSTRAINT `fk_task_watchers_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `events` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `type` longtext NOT NULL,
+  `topics` longtext,
+  `payload` longtext,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE `outbox` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `type` longtext NOT NULL,
+  `topics` longtext,
+  `payload` longtext,
+  `trace_parent` longtext,
+  `attempts` bigint,
+  `last_error` longtext,
+  `locked_until` datetime(3) NULL,
+  `delivered_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_outbox_delivered_at` (`delivered_at`)
+);
+
+CREATE TABLE `notifications` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `actor_id` bigint unsigned,
+  `task_id` bigint unsigned,
+  `type` longtext NOT NULL,
+  `message` longtext,
+  `read_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_notifications_user_id` (`user_id`),
+  INDEX `idx_notifications_task_id` (`task_id`),
+  CONSTRAINT `fk_notifications_actor` FOREIGN KEY (`actor_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_notifications_task` FOREIGN KEY (`task_id`) REFERENCES `tasks`(`id`)
+);
+
+CREATE TABLE `notification_preferences` (
+  `user_id` bigint unsigned,
+  `email_assignments` boolean DEFAULT true,
+  `email_digest` boolean DEFAULT false,
+  `unsubscribe_token` varchar(64) NOT NULL,
+  `last_digest_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`user_id`),
+  UNIQUE INDEX `idx_notification_preferences_unsubscribe_token` (`unsubscribe_token`)
+);
+
+CREATE TABLE `mentions` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `task_id` bigint unsigned NOT NULL,
+  `user_id` bigint unsigned NOT NULL,
+  `mentioned_by_id` bigint unsigned NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_mentions_task_user` (`task_id`,`user_id`),
+  INDEX `idx_mentions_user_id` (`user_id`),
+  CONSTRAINT `fk_mentions_task` FOREIGN KEY (`task_id`) REFERENCES `tasks`(`id`),
+  CONSTRAINT `fk_mentions_mentioned_by` FOREIGN KEY (`mentioned_by_id`) REFERENCES `users`(`id`)
+);
+
+CREATE TABLE `task_status_changes` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `task_id` bigint unsigned NOT NULL,
+  `from_status` longtext,
+  `to_status` longtext NOT NULL,
+  `changed_by_id` bigint unsigned,
+  `changed_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_task_status_changes_task_id` (`task_id`),
+  INDEX `idx_task_status_changes_changed_at` (`changed_at`)
+);
@@ -0,0 +1,10 @@
+ALTER TABLE `outbox` ADD COLUMN `failed_at` datetime(3) NULL;
+CREATE INDEX `idx_outbox_failed_at` ON `outbox` (`failed_at`);
+
+CREATE TABLE `outbox_dispatcher` (
+  `id` bigint unsigned,
+  `owner` varchar(255) NOT NULL DEFAULT '',
+  `locked_until` datetime(3) NULL,
+  PRIMARY KEY (`id`)
+);
+INSERT INTO `outbox_dispatcher` (`id`) VALUES (1);
@@ -0,0 +1 @@
+DROP TABLE `email_outbox`;
@@ -0,0 +1,15 @@
+CREATE TABLE `email_outbox` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  `message` longtext,
+  `trace_parent` longtext,
+  `attempts` bigint,
+  `last_error` longtext,
+  `locked_until` datetime(3) NULL,
+  `sent_at` datetime(3) NULL,
+  `failed_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_email_outbox_sent_at` (`sent_at`),
+  INDEX `idx_email_outbox_failed_at` (`failed_at`)
+);
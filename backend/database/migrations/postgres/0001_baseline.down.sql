@@ -0,0 +1,9 @@
+DROP TABLE "task_status_changes";
+DROP TABLE "mentions";
+DROP TABLE "notification_preferences";
+DROP TABLE "notifications";
+DROP TABLE "outbox";
+DROP TABLE "events";
+DROP TABLE "task_watchers";
+DROP TABLE "tasks";
+DROP TABLE "users";
@@ -0,0 +1,127 @@
+-- Baseline: the schema as created by AutoMigrate before versioned migrations.
+
+CREATE TABLE "users" (
+  "id" bigserial,
+  "username" varchar(255) NOT NULL,
+  "email" varchar(255) NOT NULL,
+  "password" text NOT NULL,
+  "role" text DEFAULT 'user',
+  "display_name" text,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE UNIQUE INDEX "idx_users_email" ON "users" ("email");
+CREATE UNIQUE INDEX "idx_users_username" ON "users" ("username");
+
+CREATE TABLE "tasks" (
+  "id" bigserial,
+  "parent_id" bigint,
+  "title" text NOT NULL,
+  "description" text,
+  "creator_id" bigint NOT NULL,
+  "assignee_id" bigint,
+  "status" text DEFAULT 'todo',
+  "due_date" timestamptz,
+  "started_at" timestamptz,
+  "completed_at" timestamptz,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  "deleted_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_tasks_subtasks" FOREIGN KEY ("parent_id") REFERENCES "tasks"("id"),
+  CONSTRAINT "fk_users_created_tasks" FOREIGN KEY ("creator_id") REFERENCES "users"("id"),
+  CONSTRAINT "fk_users_assigned_tasks" FOREIGN KEY ("assignee_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_tasks_deleted_at" ON "tasks" ("deleted_at");
+CREATE INDEX "idx_tasks_completed_at" ON "tasks" ("completed_at");
+CREATE INDEX "idx_tasks_due_date" ON "tasks" ("due_date");
+
+CREATE TABLE "task_watchers" (
+  "task_id" bigint,
+  "user_id" bigint,
+  "created_at" timestamptz,
+  PRIMARY KEY ("task_id","user_id"),
+  CONSTRAINT "fk_task_watchers_task" FOREIGN KEY ("task_id") REFERENCES "tasks"("id"),
+  CONSTRAINT "fk_task_watchers_user" FOREIGN KEY ("user_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_task_watchers_user_id" ON "task_watchers" ("user_id");
+
+CREATE TABLE "events" (
+  "id" bigserial,
+  "type" text NOT NULL,
+  "topics" text,
+  "payload" text,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+
+CREATE TABLE "outbox" (
+  "id" bigserial,
+  "type" text NOT NULL,
+  "topics" text,
+  "payload" text,
+  "trace_parent" text,
+  "attempts" bigint,
+  "last_error" text,
+  "locked_until" timestamptz,
+  "delivered_at" timestamptz,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_outbox_delivered_at" ON "outbox" ("delivered_at");
+
+CREATE TABLE "notifications" (
+  "id" bigserial,
+  "user_id" bigint NOT NULL,
+  "actor_id" bigint,
+  "task_id" bigint,
+  "type" text NOT NULL,
+  "message" text,
+  "read_at" timestamptz,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_notifications_actor" FOREIGN KEY ("actor_id") REFERENCES "users"("id"),
+  CONSTRAINT "fk_notifications_task" FOREIGN KEY ("task_id") REFERENCES "tasks"("id")
+);
+CREATE INDEX "idx_notifications_task_id" ON "notifications" ("task_id");
+CREATE INDEX "idx_notifications_user_id" ON "notifications" ("user_id");
+
+CREATE TABLE "notification_preferences" (
+  "user_id" bigint,
+  "email_assignments" boolean DEFAULT true,
+  "email_digest" boolean DEFAULT false,
+  "unsubscribe_token" varchar(64) NOT NULL,
+  "last_digest_at" timestamptz,
+  "created_at" timestamptz,
+  "updated_at" timestamptz,
+  PRIMARY KEY ("user_id")
+);
+CREATE UNIQUE INDEX "idx_notification_preferences_unsubscribe_token" ON "notification_preferences" ("unsubscribe_token");
+
+CREATE TABLE "mentions" (
+  "id" bigserial,
+  "task_id" bigint NOT NULL,
+  "user_id" bigint NOT NULL,
+  "mentioned_by_id" bigint NOT NULL,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "fk_mentions_task" FOREIGN KEY ("task_id") REFERENCES "tasks"("id"),
+  CONSTRAINT "fk_mentions_mentioned_by" FOREIGN KEY ("mentioned_by_id") REFERENCES "users"("id")
+);
+CREATE INDEX "idx_mentions_user_id" ON "mentions" ("user_id");
+CREATE UNIQUE INDEX "idx_mentions_task_user" ON "mentions" ("task_id","user_id");
+
+CREATE TABLE "task_status_changes" (
+  "id" bigserial,
+  "task_id" bigint NOT NULL,
+  "from_status" text,
+  "to_status" text NOT NULL,
+  "changed_by_id" bigint,
+  "changed_at" timestamptz NOT NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_task_status_changes_changed_at" ON "task_status_changes" ("changed_at");
+CREATE INDEX "idx_task_status_changes_task_id" ON "task_status_changes" ("task_id");
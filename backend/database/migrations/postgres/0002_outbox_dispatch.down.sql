@@ -0,0 +1,3 @@
+DROP TABLE "outbox_dispatcher";
+DROP INDEX "idx_outbox_failed_at";
+ALTER TABLE "outbox" DROP COLUMN "failed_at";
@@ -0,0 +1,10 @@
+ALTER TABLE "outbox" ADD COLUMN "failed_at" timestamptz;
+CREATE INDEX "idx_outbox_failed_at" ON "outbox" ("failed_at");
+
+CREATE TABLE "outbox_dispatcher" (
+  "id" bigint,
+  "owner" text NOT NULL DEFAULT '',
+  "locked_until" timestamptz,
+  PRIMARY KEY ("id")
+);
+INSERT INTO "outbox_dispatcher" ("id") VALUES (1);
@@ -0,0 +1,15 @@
+CREATE TABLE "email_outbox" (
+  "id" bigserial,
+  "user_id" bigint NOT NULL,
+  "message" text,
+  "trace_parent" text,
+  "attempts" bigint,
+  "last_error" text,
+  "locked_until" timestamptz,
+  "sent_at" timestamptz,
+  "failed_at" timestamptz,
+  "created_at" timestamptz,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_email_outbox_sent_at" ON "email_outbox" ("sent_at");
+CREATE INDEX "idx_email_outbox_failed_at" ON "email_outbox" ("failed_at");
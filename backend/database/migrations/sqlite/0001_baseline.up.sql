@@ -0,0 +1,120 @@
+-- Baseline: the schema as created by AutoMigrate before versioned migrations.
+
+CREATE TABLE `users` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `username` text NOT NULL,
+  `email` text NOT NULL,
+  `password` text NOT NULL,
+  `role` text DEFAULT "user",
+  `display_name` text,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime
+);
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_users_email` ON `users`(`email`);
+CREATE UNIQUE INDEX `idx_users_username` ON `users`(`username`);
+
+CREATE TABLE `tasks` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `parent_id` integer,
+  `title` text NOT NULL,
+  `description` text,
+  `creator_id` integer NOT NULL,
+  `assignee_id` integer,
+  `status` text DEFAULT "todo",
+  `due_date` datetime,
+  `started_at` datetime,
+  `completed_at` datetime,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `deleted_at` datetime,
+  CONSTRAINT `fk_tasks_subtasks` FOREIGN KEY (`parent_id`) REFERENCES `tasks`(`id`),
+  CONSTRAINT `fk_users_created_tasks` FOREIGN KEY (`creator_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_users_assigned_tasks` FOREIGN KEY (`assignee_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_tasks_deleted_at` ON `tasks`(`deleted_at`);
+CREATE INDEX `idx_tasks_completed_at` ON `tasks`(`completed_at`);
+CREATE INDEX `idx_tasks_due_date` ON `tasks`(`due_date`);
+
+CREATE TABLE `task_watchers` (
+  `task_id` integer,
+  `user_id` integer,
+  `created_at` datetime,
+  PRIMARY KEY (`task_id`,`user_id`),
+  CONSTRAINT `fk_task_watchers_task` FOREIGN KEY (`task_id`) REFERENCES `tasks`(`id`),
+  CONSTRAINT `fk_task_watchers_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_task_watchers_user_id` ON `task_watchers`(`user_id`);
+
+CREATE TABLE `events` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `type` text NOT NULL,
+  `topics` text,
+  `payload` text,
+  `created_at` datetime
+);
+
+CREATE TABLE `outbox` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `type` text NOT NULL,
+  `topics` text,
+  `payload` text,
+  `trace_parent` text,
+  `attempts` integer,
+  `last_error` text,
+  `locked_until` datetime,
+  `delivered_at` datetime,
+  `created_at` datetime
+);
+CREATE INDEX `idx_outbox_delivered_at` ON `outbox`(`delivered_at`);
+
+CREATE TABLE `notifications` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `actor_id` integer,
+  `task_id` integer,
+  `type` text NOT NULL,
+  `message` text,
+  `read_at` datetime,
+  `created_at` datetime,
+  CONSTRAINT `fk_notifications_actor` FOREIGN KEY (`actor_id`) REFERENCES `users`(`id`),
+  CONSTRAINT `fk_notifications_task` FOREIGN KEY (`task_id`) REFERENCES `tasks`(`id`)
+);
+CREATE INDEX `idx_notifications_task_id` ON `notifications`(`task_id`);
+CREATE INDEX `idx_notifications_user_id` ON `notifications`(`user_id`);
+
+CREATE TABLE `notification_preferences` (
+  `user_id` integer,
+  `email_assignments` numeric DEFAULT true,
+  `email_digest` numeric DEFAULT false,
+  `unsubscribe_token` text NOT NULL,
+  `last_digest_at` datetime,
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`user_id`)
+);
+CREATE UNIQUE INDEX `idx_notification_preferences_unsubscribe_token` ON `notification_preferences`(`unsubscribe_token`);
+
+CREATE TABLE `mentions` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `task_id` integer NOT NULL,
+  `user_id` integer NOT NULL,
+  `mentioned_by_id` integer NOT NULL,
+  `created_at` datetime,
+  CONSTRAINT `fk_mentions_task` FOREIGN KEY (`task_id`) REFERENCES `tasks`(`id`),
+  CONSTRAINT `fk_mentions_mentioned_by` FOREIGN KEY (`mentioned_by_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX `idx_mentions_user_id` ON `mentions`(`user_id`);
+CREATE UNIQUE INDEX `idx_mentions_task_user` ON `mentions`(`task_id`,`user_id`);
+
+CREATE TABLE `task_status_changes` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `task_id` integer NOT NULL,
+  `from_status` text,
+  `to_status` text NOT NULL,
+  `changed_by_id` integer,
+  `changed_at` datetime NOT NULL
+);
+CREATE INDEX `idx_task_status_changes_changed_at` ON `task_status_changes`(`changed_at`);
+CREATE INDEX `idx_task_status_changes_task_id` ON `task_status_changes`(`task_id`);
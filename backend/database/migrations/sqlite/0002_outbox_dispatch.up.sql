@@ -0,0 +1,9 @@
+ALTER TABLE `outbox` ADD COLUMN `failed_at` datetime;
+CREATE INDEX `idx_outbox_failed_at` ON `outbox`(`failed_at`);
+
+CREATE TABLE `outbox_dispatcher` (
+  `id` integer PRIMARY KEY,
+  `owner` text NOT NULL DEFAULT '',
+  `locked_until` datetime
+);
+INSERT INTO `outbox_dispatcher` (`id`) VALUES (1);
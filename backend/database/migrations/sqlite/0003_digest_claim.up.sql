@@ -0,0 +1 @@
+ALTER TABLE `notification_preferences` ADD COLUMN `digest_locked_until` datetime;
@@ -0,0 +1,14 @@
+CREATE TABLE `email_outbox` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `message` text,
+  `trace_parent` text,
+  `attempts` integer,
+  `last_error` text,
+  `locked_until` datetime,
+  `sent_at` datetime,
+  `failed_at` datetime,
+  `created_at` datetime
+);
+CREATE INDEX `idx_email_outbox_sent_at` ON `email_outbox`(`sent_at`);
+CREATE INDEX `idx_email_outbox_failed_at` ON `email_outbox`(`failed_at`);
@@ -8,8 +8,8 @@ import (
 
 type User struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
-	Username    string         `json:"username" gorm:"uniqueIndex;not null"`
-	Email       string         `json:"email" gorm:"uniqueIndex;not null"`
+	Username    string         `json:"username" gorm:"size:255;uniqueIndex;not null"`
+	Email       string         `json:"email" gorm:"size:255;uniqueIndex;not null"`
 	Password    string         `json:"password,omitempty" gorm:"not null"`
 	Role        string         `json:"role" gorm:"default:'user'"`
 	DisplayName string         `json:"display_name"`
@@ -30,6 +30,9 @@ type Task struct {
 	CreatorID   uint           `json:"creator_id" gorm:"not null"`
 	AssigneeID  *uint          `json:"assignee_id,omitempty"`
 	Status      string         `json:"status" gorm:"default:'todo'"`
+	DueDate     *time.Time     `json:"due_date,omitempty" gorm:"index"`
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty" gorm:"index"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
@@ -39,8 +42,160 @@ type Task struct {
 	Subtasks []Task `json:"subtasks,omitempty" gorm:"foreignKey:ParentID"`
 	Creator  User   `json:"creator,omitempty" gorm:"foreignKey:CreatorID"`
 	Assignee *User  `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
+	Watchers []User `json:"watchers,omitempty" gorm:"many2many:task_watchers"`
 }
 
+// MarkStatusReached sets StartedAt or CompletedAt if the task is entering
+// in_progress or done for the first time.
+func (t *Task) MarkStatusReached(at time.Time) {
+	switch t.Status {
+	case TaskStatusInProgress:
+		if t.StartedAt == nil {
+			t.StartedAt = &at
+		}
+	case TaskStatusDone:
+		if t.CompletedAt == nil {
+			t.CompletedAt = &at
+		}
+	}
+}
+
+// TaskWatcher is the join row between a task and a user following it. The
+// creator and the assignee watch a task automatically.
+type TaskWatcher struct {
+	TaskID    uint      `json:"task_id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TaskStatusChange records a task entering a status. Creation is recorded
+// as a change from the empty status, so the history starts with the task.
+type TaskStatusChange struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TaskID      uint      `json:"task_id" gorm:"not null;index"`
+	FromStatus  string    `json:"from_status"`
+	ToStatus    string    `json:"to_status" gorm:"not null"`
+	ChangedByID *uint     `json:"changed_by_id,omitempty"`
+	ChangedAt   time.Time `json:"changed_at" gorm:"not null;index"`
+}
+
+// Event is an entry in the bounded log of broadcast events. Its ID is the
+// sequence number clients use to resume after a reconnect.
+type Event struct {
+	ID        uint64    `json:"seq" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"not null"`
+	Topics    string    `json:"topics"`
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxEvent is a task event written in the same transaction as the task
+// change and published by the outbox dispatcher once committed. After a
+// failed delivery LockedUntil holds the event back until its next retry;
+// FailedAt is set when the dispatcher gives up on it.
+type OutboxEvent struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type" gorm:"not null"`
+	Topics      string     `json:"topics"`
+	Payload     string     `json:"payload"`
+	TraceParent string     `json:"trace_parent,omitempty"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty" gorm:"index"`
+	FailedAt    *time.Time `json:"failed_at,omitempty" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// OutboxDispatcher is the single row leased by the replica that dispatches
+// the outbox, so events go out one at a time and in order.
+type OutboxDispatcher struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement:false"`
+	Owner       string `gorm:"not null"`
+	LockedUntil *time.Time
+}
+
+func (OutboxDispatcher) TableName() string {
+	return "outbox_dispatcher"
+}
+
+// OutboxEmail is an assignment email written in the same transaction as its
+// notification. Emails are sent apart from the event outbox, so a failing
+// mail server never holds back or repeats realtime events. LockedUntil
+// claims the email while a replica sends it and, after a failed send, holds
+// it back until its next retry; FailedAt is set when sending gives up.
+type OutboxEmail struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null"`
+	Message     string     `json:"message"`
+	TraceParent string     `json:"trace_parent,omitempty"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty" gorm:"index"`
+	FailedAt    *time.Time `json:"failed_at,omitempty" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (OutboxEmail) TableName() string {
+	return "email_outbox"
+}
+
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	ActorID   *uint      `json:"actor_id,omitempty"`
+	TaskID    *uint      `json:"task_id,omitempty" gorm:"index"`
+	Type      string     `json:"type" gorm:"not null"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Relationships
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Task  *Task `json:"task,omitempty" gorm:"foreignKey:TaskID"`
+}
+
+// NotificationPreference holds a user's email settings. UnsubscribeToken
+// lets the links in emails change them without logging in.
+type NotificationPreference struct {
+	UserID           uint       `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	EmailAssignments bool       `json:"email_assignments" gorm:"default:true"`
+	EmailDigest      bool       `json:"email_digest" gorm:"default:false"`
+	UnsubscribeToken string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	LastDigestAt     *time.Time `json:"last_digest_at,omitempty"`
+	// DigestLockedUntil is set while a replica is sending the digest.
+	DigestLockedUntil *time.Time `json:"-"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Mention records that a task's description mentions a user. Rows are kept
+// in sync with the current description.
+type Mention struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	TaskID        uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_mentions_task_user"`
+	UserID        uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_mentions_task_user;index"`
+	MentionedByID uint      `json:"mentioned_by_id" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Relationships
+	Task        *Task `json:"task,omitempty" gorm:"foreignKey:TaskID"`
+	MentionedBy *User `json:"mentioned_by,omitempty" gorm:"foreignKey:MentionedByID"`
+}
+
+const (
+	NotificationTypeAssigned      = "assigned"
+	NotificationTypeStatusChanged = "status_changed"
+	NotificationTypeMentioned     = "mentioned"
+	NotificationTypeTaskUpdated   = "task_updated"
+	NotificationTypeTaskDeleted   = "task_deleted"
+)
+
 const (
 	TaskStatusTodo       = "todo"
 	TaskStatusInProgress = "in_progress"
@@ -1,7 +1,8 @@
 package database
 
 import (
-	"log"
+	"log/slog"
+	"time"
 
 	"ziggler_backend/auth"
 )
@@ -11,11 +12,11 @@ func SeedDatabase() {
 	DB.Model(&User{}).Count(&userCount)
 
 	if userCount > 0 {
-		log.Println("Database already seeded, skipping...")
+		slog.Info("Database already seeded, skipping")
 		return
 	}
 
-	log.Println("Seeding database with initial data...")
+	slog.Info("Seeding database with initial data")
 
 	hashedPassword, _ := auth.HashPassword("password123")
 
@@ -38,7 +39,7 @@ func SeedDatabase() {
 
 	for _, user := range users {
 		if err := DB.Create(&user).Error; err != nil {
-			log.Printf("Failed to create user %s: %v", user.Username, err)
+			slog.Error("Failed to create user", "username", user.Username, "error", err)
 		}
 	}
 
@@ -69,10 +70,24 @@ func SeedDatabase() {
 	}
 
 	for _, task := range tasks {
+		task.MarkStatusReached(time.Now())
 		if err := DB.Create(&task).Error; err != nil {
-			log.Printf("Failed to create task %s: %v", task.Title, err)
+			slog.Error("Failed to create task", "title", task.Title, "error", err)
+			continue
+		}
+
+		if err := DB.Create(&TaskStatusChange{TaskID: task.ID, ToStatus: task.Status, ChangedAt: task.CreatedAt}).Error; err != nil {
+			slog.Error("Failed to record task status", "task_id", task.ID, "error", err)
+		}
+
+		watchers := []TaskWatcher{{TaskID: task.ID, UserID: task.CreatorID}}
+		if task.AssigneeID != nil && *task.AssigneeID != task.CreatorID {
+			watchers = append(watchers, TaskWatcher{TaskID: task.ID, UserID: *task.AssigneeID})
+		}
+		if err := DB.Create(&watchers).Error; err != nil {
+			slog.Error("Failed to add task watchers", "task_id", task.ID, "error", err)
 		}
 	}
 
-	log.Println("Database seeding completed!")
+	slog.Info("Database seeding completed")
 }
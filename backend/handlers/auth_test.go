@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+type authResponse struct {
+	Token string `json:"token"`
+	User  struct {
+		ID       uint   `json:"id"`
+		Username string `json:"username"`
+		Email    string `json:"email"`
+		Role     string `json:"role"`
+	} `json:"user"`
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	setup(t)
+
+	w := request(t, http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"username":     "carol",
+		"email":        "carol@example.com",
+		"password":     "secret123",
+		"display_name": "Carol",
+	})
+	expectStatus(t, w, http.StatusCreated)
+	registered := decode[authResponse](t, w)
+	if registered.Token == "" {
+		t.Fatal("register returned no token")
+	}
+	if registered.User.Role != "user" {
+		t.Errorf("role = %q, want user", registered.User.Role)
+	}
+
+	w = request(t, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email":    "carol@example.com",
+		"password": "secret123",
+	})
+	expectStatus(t, w, http.StatusOK)
+	login := decode[authResponse](t, w)
+	if login.User.ID != registered.User.ID {
+		t.Errorf("login user id = %d, want %d", login.User.ID, registered.User.ID)
+	}
+
+	w = request(t, http.MethodGet, "/api/v1/profile", login.Token, nil)
+	expectStatus(t, w, http.StatusOK)
+	if profile := decode[map[string]interface{}](t, w); profile["username"] != "carol" {
+		t.Errorf("profile username = %v, want carol", profile["username"])
+	}
+}
+
+func TestRegisterRejectsDuplicateEmail(t *testing.T) {
+	f := setup(t)
+
+	w := request(t, http.MethodPost, "/api/v1/auth/register", "", map[string]string{
+		"username":     "alice2",
+		"email":        f.Alice.Email,
+		"password":     "secret123",
+		"display_name": "Alice Again",
+	})
+	expectStatus(t, w, http.StatusConflict)
+}
+
+func TestRegisterValidatesRequest(t *testing.T) {
+	setup(t)
+
+	tests := map[string]map[string]string{
+		"short password": {"username": "dave", "email": "dave@example.com", "password": "123", "display_name": "Dave"},
+		"missing email":  {"username": "dave", "password": "secret123", "display_name": "Dave"},
+		"missing name":   {"username": "dave", "email": "dave@example.com", "password": "secret123"},
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := request(t, http.MethodPost, "/api/v1/auth/register", "", body)
+			expectStatus(t, w, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestLoginRejectsBadCredentials(t *testing.T) {
+	f := setup(t)
+
+	tests := map[string]map[string]string{
+		"wrong password": {"email": f.Alice.Email, "password": "wrong-password"},
+		"unknown email":  {"email": "nobody@example.com", "password": testPassword},
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := request(t, http.MethodPost, "/api/v1/auth/login", "", body)
+			expectStatus(t, w, http.StatusUnauthorized)
+			if msg := errorMessage(t, w); msg != "Invalid credentials" {
+				t.Errorf("error = %q, want Invalid credentials", msg)
+			}
+		})
+	}
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	setup(t)
+
+	for name, token := range map[string]string{"no token": "", "invalid token": "not-a-jwt"} {
+		t.Run(name, func(t *testing.T) {
+			for _, path := range []string{"/api/v1/profile", "/api/v1/tasks", "/api/v1/stats"} {
+				w := request(t, http.MethodGet, path, token, nil)
+				expectStatus(t, w, http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"ziggler_backend/config"
+	"ziggler_backend/database"
+	"ziggler_backend/mailer"
+	"ziggler_backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"gorm.io/gorm"
+)
+
+type NotificationPreference = database.NotificationPreference
+
+type NotificationPreferenceRequest struct {
+	EmailAssignments *bool `json:"email_assignments,omitempty"`
+	EmailDigest      *bool `json:"email_digest,omitempty"`
+}
+
+const (
+	// How often the digest scheduler checks for users whose digest is due.
+	digestCheckInterval = 10 * time.Minute
+
+	// How long a replica may hold a user's digest while sending it. A digest
+	// whose send failed or was interrupted is retried on a later check.
+	digestClaim = 5 * time.Minute
+
+	// How long one email may take to send.
+	mailSendTimeout = 5 * time.Second
+
+	// How long a replica may hold a queued email while sending it. An email
+	// whose send was interrupted is sent again once the claim lapses.
+	emailClaim = time.Minute
+)
+
+var (
+	emailDone     = make(chan struct{})
+	emailStopOnce sync.Once
+	emailWorkers  sync.WaitGroup
+
+	emailWake = make(chan struct{}, 1)
+)
+
+// StartEmailNotifications starts sending queued assignment emails and the
+// daily digest scheduler.
+func StartEmailNotifications() {
+	emailWorkers.Add(2)
+	go runEmailOutbox()
+	go runDigests()
+	slog.Info("Email notifications started")
+}
+
+// StopEmailNotifications stops the email sender and the digest scheduler,
+// waiting for the email or digest being sent, if any. Emails not sent yet
+// stay queued for the next start.
+func StopEmailNotifications() {
+	emailStopOnce.Do(func() {
+		close(emailDone)
+	})
+	emailWorkers.Wait()
+}
+
+// preferencesFor returns the user's preferences, creating the defaults and
+// an unsubscribe token on first use.
+func preferencesFor(db *gorm.DB, userID uint) (NotificationPreference, error) {
+	var prefs NotificationPreference
+	err := db.Where(NotificationPreference{UserID: userID}).
+		Attrs(NotificationPreference{UnsubscribeToken: newUnsubscribeToken(), EmailAssignments: true}).
+		FirstOrCreate(&prefs).Error
+	return prefs, err
+}
+
+func newUnsubscribeToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func unsubscribeURL(prefs NotificationPreference, list string) string {
+	return fmt.Sprintf("%s/api/v1/unsubscribe?token=%s&list=%s",
+		strings.TrimRight(config.AppConfig.AppBaseURL, "/"), prefs.UnsubscribeToken, url.QueryEscape(list))
+}
+
+// enqueueEmail queues the email for an assignment notification as part of
+// tx. It is sent once tx commits, unless the recipient has opted out by
+// then, continuing the trace of tx's context.
+func enqueueEmail(tx *gorm.DB, notification Notification) error {
+	return tx.Create(&database.OutboxEmail{
+		UserID:      notification.UserID,
+		Message:     notification.Message,
+		TraceParent: tracing.Inject(tx.Statement.Context),
+	}).Error
+}
+
+// wakeEmails tells the sender that new emails were committed so they go out
+// without waiting for the next poll.
+func wakeEmails() {
+	select {
+	case emailWake <- struct{}{}:
+	default:
+	}
+}
+
+// runEmailOutbox sends queued emails. Every replica runs it and claims each
+// email before sending it, so an email is sent by one replica at a time.
+// Emails do not depend on each other's order, so a failing one waits for
+// its retry without holding back the rest.
+func runEmailOutbox() {
+	defer emailWorkers.Done()
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Now()
+	for {
+		for sendQueuedEmails() == outboxBatchSize {
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			database.DB.Where("sent_at < ?", time.Now().Add(-outboxRetention)).Delete(&database.OutboxEmail{})
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-emailWake:
+		case <-emailDone:
+			return
+		}
+	}
+}
+
+// sendQueuedEmails sends up to outboxBatchSize due emails and returns how
+// many it claimed. A failed email is retried with the same backoff as an
+// outbox event and given up on, with failed_at set, after as many attempts.
+func sendQueuedEmails() int {
+	const due = "sent_at IS NULL AND failed_at IS NULL AND (locked_until IS NULL OR locked_until < ?)"
+
+	var emails []database.OutboxEmail
+	if err := database.DB.Where(due, time.Now()).Order("id asc").Limit(outboxBatchSize).Find(&emails).Error; err != nil {
+		slog.Error("Failed to load queued emails", "error", err)
+		return 0
+	}
+
+	claimed := 0
+	for _, email := range emails {
+		select {
+		case <-emailDone:
+			return 0
+		default:
+		}
+
+		claim := database.DB.Model(&email).Where(due, time.Now()).Update("locked_until", time.Now().Add(emailClaim))
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+		claimed++
+
+		attempts := email.Attempts + 1
+		if err := sendOutboxEmail(email); err != nil {
+			updates := map[string]interface{}{
+				"attempts":   attempts,
+				"last_error": err.Error(),
+			}
+			if attempts >= outboxMaxAttempts {
+				slog.Error("Giving up on email after repeated failures", "email_id", email.ID, "user_id", email.UserID, "attempts", attempts, "error", err)
+				updates["failed_at"] = time.Now()
+				updates["locked_until"] = nil
+			} else {
+				slog.Error("Failed to send email", "email_id", email.ID, "user_id", email.UserID, "attempts", attempts, "error", err)
+				updates["locked_until"] = time.Now().Add(outboxPollInterval << (attempts - 1))
+			}
+			database.DB.Model(&email).Updates(updates)
+			continue
+		}
+
+		database.DB.Model(&email).Updates(map[string]interface{}{
+			"attempts":     attempts,
+			"sent_at":      time.Now(),
+			"locked_until": nil,
+		})
+	}
+	return claimed
+}
+
+// sendOutboxEmail emails an assignment to its recipient if they have not
+// opted out.
+func sendOutboxEmail(email database.OutboxEmail) (err error) {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), email.TraceParent), "email.send",
+		attribute.Int64("email.id", int64(email.ID)),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var user User
+	if err := database.DB.WithContext(ctx).First(&user, email.UserID).Error; err != nil {
+		// The user is gone; there is nobody to email.
+		return nil
+	}
+
+	prefs, err := preferencesFor(database.DB.WithContext(ctx), user.ID)
+	if err != nil {
+		return err
+	}
+	if !prefs.EmailAssignments {
+		return nil
+	}
+
+	link := unsubscribeURL(prefs, "assignments")
+	ctx, cancel := context.WithTimeout(ctx, mailSendTimeout)
+	defer cancel()
+	err = mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: email.Message,
+		Body: fmt.Sprintf("Hi %s,\n\n%s.\n\nTo stop receiving assignment emails, visit %s\n",
+			displayName(user), email.Message, link),
+		UnsubscribeURL: link,
+	})
+	if err != nil {
+		return fmt.Errorf("sending assignment email: %w", err)
+	}
+	return nil
+}
+
+func displayName(user User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Username
+}
+
+func runDigests() {
+	defer emailWorkers.Done()
+
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		sendDueDigests(time.Now())
+
+		select {
+		case <-ticker.C:
+		case <-emailDone:
+			return
+		}
+	}
+}
+
+// sendDueDigests emails every opted-in user who has not had today's digest
+// yet, once the configured hour has passed. Each digest is claimed with a
+// conditional update so other replicas do not send it at the same time, and
+// last_digest_at only moves once it has been sent.
+func sendDueDigests(now time.Time) {
+	if now.Hour() < config.AppConfig.DigestHour {
+		return
+	}
+	digestTime := time.Date(now.Year(), now.Month(), now.Day(), config.AppConfig.DigestHour, 0, 0, 0, now.Location())
+
+	var due []NotificationPreference
+	if err := database.DB.Where("email_digest = ? AND (last_digest_at IS NULL OR last_digest_at < ?)", true, digestTime).Find(&due).Error; err != nil {
+		slog.Error("Failed to load digest subscribers", "error", err)
+		return
+	}
+
+	for _, prefs := range due {
+		select {
+		case <-emailDone:
+			return
+		default:
+		}
+
+		claim := database.DB.Model(&NotificationPreference{}).
+			Where("user_id = ? AND (last_digest_at IS NULL OR last_digest_at < ?)", prefs.UserID, digestTime).
+			Where("digest_locked_until IS NULL OR digest_locked_until < ?", time.Now()).
+			Update("digest_locked_until", time.Now().Add(digestClaim))
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		updates := map[string]interface{}{"digest_locked_until": nil}
+		if err := sendDigest(prefs, now); err != nil {
+			slog.Error("Failed to send digest", "user_id", prefs.UserID, "error", err)
+		} else {
+			updates["last_digest_at"] = now
+		}
+		database.DB.Model(&NotificationPreference{}).Where("user_id = ?", prefs.UserID).Updates(updates)
+	}
+}
+
+func sendDigest(prefs NotificationPreference, now time.Time) error {
+	var user User
+	if err := database.DB.First(&user, prefs.UserID).Error; err != nil {
+		return err
+	}
+
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	openStatuses := []string{database.TaskStatusTodo, database.TaskStatusInProgress}
+
+	var dueTasks []Task
+	if err := database.DB.
+		Where("assignee_id = ? AND due_date IS NOT NULL AND due_date < ? AND status IN ?", user.ID, endOfDay, openStatuses).
+		Order("due_date asc").
+		Find(&dueTasks).Error; err != nil {
+		return err
+	}
+
+	var changedTasks []Task
+	if err := database.DB.
+		Where("(assignee_id = ? OR creator_id = ?) AND updated_at >= ?", user.ID, user.ID, now.Add(-24*time.Hour)).
+		Order("updated_at desc").
+		Limit(50).
+		Find(&changedTasks).Error; err != nil {
+		return err
+	}
+
+	if len(dueTasks) == 0 && len(changedTasks) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %s,\n\nHere is your Ziggler digest for %s.\n", displayName(user), now.Format("Monday, January 2"))
+
+	if len(dueTasks) > 0 {
+		body.WriteString("\nDue today or overdue:\n")
+		for _, task := range dueTasks {
+			label := "due today"
+			if task.DueDate.Before(endOfDay.AddDate(0, 0, -1)) {
+				label = "overdue since " + task.DueDate.Format("Jan 2")
+			}
+			fmt.Fprintf(&body, "- #%d %s (%s, %s)\n", task.ID, task.Title, task.Status, label)
+		}
+	}
+
+	if len(changedTasks) > 0 {
+		body.WriteString("\nChanged in the last 24 hours:\n")
+		for _, task := range changedTasks {
+			fmt.Fprintf(&body, "- #%d %s (%s)\n", task.ID, task.Title, task.Status)
+		}
+	}
+
+	link := unsubscribeURL(prefs, "digest")
+	fmt.Fprintf(&body, "\nTo stop receiving the daily digest, visit %s\n", link)
+
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+	return mailer.Send(ctx, mailer.Message{
+		To:             user.Email,
+		Subject:        fmt.Sprintf("Your Ziggler digest: %d due, %d changed", len(dueTasks), len(changedTasks)),
+		Body:           body.String(),
+		UnsubscribeURL: link,
+	})
+}
+
+func GetNotificationPreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	prefs, err := preferencesFor(dbFor(c), uint(userID.(int)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req NotificationPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	prefs, err := preferencesFor(dbFor(c), uint(userID.(int)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification preferences"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.EmailAssignments != nil {
+		updates["email_assignments"] = *req.EmailAssignments
+	}
+	if req.EmailDigest != nil {
+		updates["email_digest"] = *req.EmailDigest
+	}
+
+	if len(updates) > 0 {
+		if err := dbFor(c).Model(&prefs).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// unsubscribeList is what the list parameter of an unsubscribe link names:
+// the preferences it turns off.
+type unsubscribeList struct {
+	description string
+	columns     []string
+}
+
+var unsubscribeLists = map[string]unsubscribeList{
+	"assignments": {"assignment emails", []string{"email_assignments"}},
+	"digest":      {"the daily digest", []string{"email_digest"}},
+	"all":         {"all Ziggler emails", []string{"email_assignments", "email_digest"}},
+}
+
+// unsubscribePage is what people following an unsubscribe link see. The
+// confirmation form has no action, so it posts back to the same URL.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Ziggler email preferences</title></head>
+<body>
+<p>{{.Message}}</p>
+{{if .Confirm}}<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+type unsubscribePageData struct {
+	Message string
+	Confirm bool
+}
+
+func renderUnsubscribePage(c *gin.Context, status int, data unsubscribePageData) {
+	var page bytes.Buffer
+	if err := unsubscribePage.Execute(&page, data); err != nil {
+		slog.Error("Failed to render the unsubscribe page", "error", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", page.Bytes())
+}
+
+// unsubscribeTarget reads the token and list of an unsubscribe link,
+// answering with an error page if either is missing or invalid.
+func unsubscribeTarget(c *gin.Context) (string, unsubscribeList, bool) {
+	token := c.Query("token")
+	if token == "" {
+		renderUnsubscribePage(c, http.StatusBadRequest, unsubscribePageData{Message: "This unsubscribe link is missing its token."})
+		return "", unsubscribeList{}, false
+	}
+	list, ok := unsubscribeLists[c.DefaultQuery("list", "all")]
+	if !ok {
+		renderUnsubscribePage(c, http.StatusBadRequest, unsubscribePageData{Message: "This unsubscribe link is not valid."})
+		return "", unsubscribeList{}, false
+	}
+	return token, list, true
+}
+
+// ConfirmUnsubscribe serves the page behind the links in notification
+// emails. It needs no login; the token identifies the user. It changes
+// nothing, since mail scanners and link previews follow links too: the
+// page's button posts to Unsubscribe.
+func ConfirmUnsubscribe(c *gin.Context) {
+	token, list, ok := unsubscribeTarget(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	if err := dbFor(c).Model(&NotificationPreference{}).Where("unsubscribe_token = ?", token).Count(&count).Error; err != nil {
+		renderUnsubscribePage(c, http.StatusInternalServerError, unsubscribePageData{Message: "Something went wrong. Please try again later."})
+		return
+	}
+	if count == 0 {
+		renderUnsubscribePage(c, http.StatusNotFound, unsubscribePageData{Message: "This unsubscribe link is not valid."})
+		return
+	}
+
+	renderUnsubscribePage(c, http.StatusOK, unsubscribePageData{
+		Message: fmt.Sprintf("Unsubscribe from %s?", list.description),
+		Confirm: true,
+	})
+}
+
+// Unsubscribe turns off the emails an unsubscribe link is for. It is posted
+// by the confirmation page and, for one-click unsubscribe (RFC 8058), by
+// mail clients, whose List-Unsubscribe=One-Click body needs no handling.
+// list is "assignments", "digest" or "all".
+func Unsubscribe(c *gin.Context) {
+	token, list, ok := unsubscribeTarget(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	for _, column := range list.columns {
+		updates[column] = false
+	}
+	result := dbFor(c).Model(&NotificationPreference{}).Where("unsubscribe_token = ?", token).Updates(updates)
+	if result.Error != nil {
+		renderUnsubscribePage(c, http.StatusInternalServerError, unsubscribePageData{Message: "Something went wrong. Please try again later."})
+		return
+	}
+	if result.RowsAffected == 0 {
+		renderUnsubscribePage(c, http.StatusNotFound, unsubscribePageData{Message: "This unsubscribe link is not valid."})
+		return
+	}
+
+	renderUnsubscribePage(c, http.StatusOK, unsubscribePageData{Message: fmt.Sprintf("You have been unsubscribed from %s.", list.description)})
+}
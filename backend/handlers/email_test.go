@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bufio"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ziggler_backend/database"
+	"ziggler_backend/mailer"
+)
+
+// smtpSink is a minimal SMTP server that records the messages it accepts.
+// While failing is set it rejects every sender with a temporary error.
+type smtpSink struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []*mail.Message
+	failing  bool
+}
+
+// startSMTPSink starts a sink and points the mailer at it until the test
+// ends.
+func startSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &smtpSink{listener: listener}
+	go sink.serve()
+
+	previous := mailer.Default
+	mailer.Default = &mailer.SMTPMailer{Addr: listener.Addr().String(), From: "ziggler@example.com", Host: "127.0.0.1"}
+	t.Cleanup(func() {
+		mailer.Default = previous
+		listener.Close()
+	})
+	return sink
+}
+
+func (s *smtpSink) setFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+func (s *smtpSink) received() []*mail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*mail.Message(nil), s.messages...)
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(command, "MAIL"):
+			s.mu.Lock()
+			failing := s.failing
+			s.mu.Unlock()
+			if failing {
+				reply("451 try again later")
+				continue
+			}
+			reply("250 ok")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			if msg, err := mail.ReadMessage(strings.NewReader(data.String())); err == nil {
+				s.mu.Lock()
+				s.messages = append(s.messages, msg)
+				s.mu.Unlock()
+			}
+			reply("250 queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestAssignmentEmailKeepsTitleInSubject(t *testing.T) {
+	f := setup(t)
+	sink := startSMTPSink(t)
+
+	createTask(t, f.AliceToken, map[string]interface{}{
+		"title":       "Fix login\r\nBcc: eve@example.com",
+		"assignee_id": f.Bob.ID,
+	})
+	eventually(t, "the assignment email", func() bool { return len(sink.received()) == 1 })
+
+	msg := sink.received()[0]
+	if to := msg.Header.Get("To"); to != f.Bob.Email {
+		t.Errorf("To = %q, want %q", to, f.Bob.Email)
+	}
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("title injected a Bcc header: %q", bcc)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(subject, "Fix login Bcc: eve@example.com") {
+		t.Errorf("subject = %q, want the title on one line", subject)
+	}
+}
+
+func TestAssignmentEmailRetriedWhenSMTPFails(t *testing.T) {
+	f := setup(t)
+	sink := startSMTPSink(t)
+	sink.setFailing(true)
+	conn := dialWebSocket(t, f.BobToken, TopicTasks)
+
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Retry me", "assignee_id": f.Bob.ID})
+	var email database.OutboxEmail
+	eventually(t, "a retried email", func() bool {
+		database.DB.Where("user_id = ?", f.Bob.ID).First(&email)
+		return email.Attempts >= 2
+	})
+	if !strings.Contains(email.LastError, "assignment email") {
+		t.Errorf("last_error = %q, want the send error", email.LastError)
+	}
+	if got := len(sink.received()); got != 0 {
+		t.Fatalf("received %d emails while failing", got)
+	}
+	if !outboxDrained() {
+		t.Error("the failing email held back the outbox")
+	}
+
+	// Events committed while the email is failing go out at once, and the
+	// assignment's own events were broadcast exactly once.
+	start := time.Now()
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Marker"})
+	counts := map[string]int{}
+	for _, event := range readUntilTask(t, conn, "Marker") {
+		counts[event.Type]++
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("the next task event took %v", waited)
+	}
+	if counts["notification_created"] != 1 || counts["task_created"] != 2 {
+		t.Errorf("got %d notification_created and %d task_created messages, want 1 and 2", counts["notification_created"], counts["task_created"])
+	}
+
+	sink.setFailing(false)
+	eventually(t, "the email after the retry", func() bool { return len(sink.received()) == 1 })
+}
+
+func TestDigestAdvancesOnlyAfterSend(t *testing.T) {
+	f := setup(t)
+	sink := startSMTPSink(t)
+
+	due := time.Now()
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Due today", "assignee_id": f.Alice.ID, "due_date": due})
+	eventually(t, "the outbox to drain", outboxDrained)
+	before := len(sink.received())
+
+	prefs, err := preferencesFor(database.DB, f.Alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Model(&prefs).Update("email_digest", true)
+	now := time.Date(due.Year(), due.Month(), due.Day(), 23, 0, 0, 0, due.Location())
+
+	sink.setFailing(true)
+	sendDueDigests(now)
+	if err := database.DB.First(&prefs, f.Alice.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if prefs.LastDigestAt != nil || prefs.DigestLockedUntil != nil {
+		t.Fatalf("after a failed send last_digest_at = %v, claim = %v; want both unset", prefs.LastDigestAt, prefs.DigestLockedUntil)
+	}
+
+	sink.setFailing(false)
+	sendDueDigests(now)
+	if got := len(sink.received()) - before; got != 1 {
+		t.Fatalf("sent %d digests, want 1", got)
+	}
+	if err := database.DB.First(&prefs, f.Alice.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if prefs.LastDigestAt == nil || !prefs.LastDigestAt.Equal(now) {
+		t.Errorf("last_digest_at = %v, want %v", prefs.LastDigestAt, now)
+	}
+
+	// Today's digest is not sent twice.
+	sendDueDigests(now.Add(time.Minute))
+	if got := len(sink.received()) - before; got != 1 {
+		t.Errorf("sent %d digests after a second run, want 1", got)
+	}
+}
+
+func TestUnsubscribeNeedsConfirmation(t *testing.T) {
+	f := setup(t)
+	prefs, err := preferencesFor(database.DB, f.Bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := "/api/v1/unsubscribe?token=" + prefs.UnsubscribeToken + "&list=assignments"
+
+	// Following the link only shows the confirmation page.
+	w := request(t, http.MethodGet, link, "", nil)
+	expectStatus(t, w, http.StatusOK)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), `<form method="post">`) {
+		t.Errorf("GET answered %q: %s, want the confirmation page", w.Header().Get("Content-Type"), w.Body.String())
+	}
+	if database.DB.First(&prefs, f.Bob.ID); !prefs.EmailAssignments {
+		t.Fatal("GET unsubscribed Bob")
+	}
+
+	// A one-click unsubscribe from a mail client (RFC 8058).
+	req := httptest.NewRequest(http.MethodPost, link, strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	expectStatus(t, w, http.StatusOK)
+	if database.DB.First(&prefs, f.Bob.ID); prefs.EmailAssignments {
+		t.Error("POST left assignment emails on")
+	}
+
+	for _, tc := range []struct {
+		name, method, path string
+		status             int
+	}{
+		{"unknown token", http.MethodGet, "/api/v1/unsubscribe?token=nope", http.StatusNotFound},
+		{"unknown token", http.MethodPost, "/api/v1/unsubscribe?token=nope", http.StatusNotFound},
+		{"missing token", http.MethodPost, "/api/v1/unsubscribe", http.StatusBadRequest},
+		{"unknown list", http.MethodGet, "/api/v1/unsubscribe?token=" + prefs.UnsubscribeToken + "&list=spam", http.StatusBadRequest},
+	} {
+		t.Run(tc.method+" "+tc.name, func(t *testing.T) {
+			expectStatus(t, request(t, tc.method, tc.path, "", nil), tc.status)
+		})
+	}
+}
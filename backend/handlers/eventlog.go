@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"ziggler_backend/database"
+)
+
+const (
+	// Number of events kept in the log for replay.
+	eventLogSize = 1000
+
+	// The log is trimmed back to eventLogSize once this many more events
+	// have been appended, rather than on every append.
+	eventLogTrimBatch = 100
+
+	// Maximum number of missed events replayed to a single client. Larger
+	// gaps are answered with resync_required.
+	wsMaxReplay = 128
+)
+
+var errResyncRequired = errors.New("resync required")
+
+// appendEvent persists a broadcast message and returns its sequence number.
+// It is only called by the outbox dispatcher, one event at a time, so events
+// are numbered in dispatch order. Only about the most recent eventLogSize
+// events are kept.
+func appendEvent(msg WSMessage) (uint64, error) {
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return 0, err
+	}
+
+	event := database.Event{
+		Type:    msg.Type,
+		Topics:  strings.Join(msg.topics, ","),
+		Payload: string(payload),
+	}
+	db := database.DB.WithContext(msg.traceContext())
+	if err := db.Create(&event).Error; err != nil {
+		return 0, err
+	}
+
+	if event.ID > eventLogSize && event.ID%eventLogTrimBatch == 0 {
+		db.Where("id <= ?", event.ID-eventLogSize).Delete(&database.Event{})
+	}
+
+	return event.ID, nil
+}
+
+func latestSeq() uint64 {
+	var seq uint64
+	database.DB.Model(&database.Event{}).Select("COALESCE(MAX(id), 0)").Scan(&seq)
+	return seq
+}
+
+// missedEvents returns the logged events after resumeFrom that match any of
+// the given topics, along with the last sequence number the log covered. It
+// returns errResyncRequired when the log no longer covers the gap or the gap
+// is too large to replay.
+func missedEvents(resumeFrom uint64, topics map[string]bool) ([]WSMessage, uint64, error) {
+	var events []database.Event
+	if err := database.DB.Where("id > ?", resumeFrom).Order("id asc").Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if len(events) == 0 {
+		if resumeFrom > latestSeq() {
+			return nil, 0, errResyncRequired
+		}
+		return nil, resumeFrom, nil
+	}
+
+	if events[0].ID != resumeFrom+1 {
+		return nil, 0, errResyncRequired
+	}
+
+	var missed []WSMessage
+	for _, event := range events {
+		if !matchesAny(strings.Split(event.Topics, ","), topics) {
+			continue
+		}
+		if len(missed) == wsMaxReplay {
+			return nil, 0, errResyncRequired
+		}
+		missed = append(missed, WSMessage{
+			Seq:     event.ID,
+			Type:    event.Type,
+			Payload: json.RawMessage(event.Payload),
+		})
+	}
+
+	return missed, events[len(events)-1].ID, nil
+}
+
+func matchesAny(eventTopics []string, topics map[string]bool) bool {
+	for _, topic := range eventTopics {
+		if topics[topic] {
+			return true
+		}
+	}
+	return false
+}
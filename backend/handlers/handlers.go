@@ -1,26 +1,28 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"ziggler_backend/auth"
 	"ziggler_backend/database"
+	"ziggler_backend/repository"
+	"ziggler_backend/service"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type User = database.User
 type Task = database.Task
+type TaskUpdateRequest = service.TaskUpdate
 
-type TaskUpdateRequest struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
-	Status      *string `json:"status,omitempty"`
-	AssigneeID  *uint   `json:"assignee_id,omitempty"`
-	ParentID    *uint   `json:"parent_id,omitempty"`
-	Unassigned  bool    `json:"unassigned,omitempty"`
+// dbFor returns the database handle bound to the request's context, so
+// query logs carry the request ID.
+func dbFor(c *gin.Context) *gorm.DB {
+	return database.DB.WithContext(c.Request.Context())
 }
 
 func HealthCheck(c *gin.Context) {
@@ -30,16 +32,25 @@ func HealthCheck(c *gin.Context) {
 	})
 }
 
-func GetUsers(c *gin.Context) {
-	var users []User
-	if err := database.DB.Find(&users).Error; err != nil {
+// UserHandler serves the user management endpoints.
+type UserHandler struct {
+	users repository.UserRepository
+}
+
+func NewUserHandler(users repository.UserRepository) *UserHandler {
+	return &UserHandler{users: users}
+}
+
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	users, err := h.users.List(c.Request.Context())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
 	c.JSON(http.StatusOK, users)
 }
 
-func GetUser(c *gin.Context) {
+func (h *UserHandler) GetUser(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
@@ -47,8 +58,8 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	var user User
-	if err := database.DB.First(&user, uint(id)).Error; err != nil {
+	user, err := h.users.Get(c.Request.Context(), uint(id))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
@@ -56,7 +67,7 @@ func GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-func CreateUser(c *gin.Context) {
+func (h *UserHandler) CreateUser(c *gin.Context) {
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
@@ -70,15 +81,16 @@ func CreateUser(c *gin.Context) {
 	}
 	user.Password = hashedPassword
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err := h.users.Create(c.Request.Context(), &user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	userStatsChanged(c, user.ID)
 
 	c.JSON(http.StatusCreated, user)
 }
 
-func UpdateUser(c *gin.Context) {
+func (h *UserHandler) UpdateUser(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
@@ -86,8 +98,8 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var user User
-	if err := database.DB.First(&user, uint(id)).Error; err != nil {
+	user, err := h.users.Get(c.Request.Context(), uint(id))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
@@ -101,15 +113,16 @@ func UpdateUser(c *gin.Context) {
 	updatedUser.ID = user.ID
 	updatedUser.CreatedAt = user.CreatedAt
 
-	if err := database.DB.Save(&updatedUser).Error; err != nil {
+	if err := h.users.Save(c.Request.Context(), &updatedUser); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
+	userStatsChanged(c, updatedUser.ID)
 
 	c.JSON(http.StatusOK, updatedUser)
 }
 
-func DeleteUser(c *gin.Context) {
+func (h *UserHandler) DeleteUser(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
@@ -117,23 +130,33 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := database.DB.Delete(&User{}, uint(id)).Error; err != nil {
+	if err := h.users.Delete(c.Request.Context(), uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	userStatsChanged(c, uint(id))
 
 	c.Status(http.StatusNoContent)
 }
 
-func Login(c *gin.Context) {
+// AuthHandler serves registration, login and the caller's profile.
+type AuthHandler struct {
+	users repository.UserRepository
+}
+
+func NewAuthHandler(users repository.UserRepository) *AuthHandler {
+	return &AuthHandler{users: users}
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
 	var loginReq auth.LoginRequest
 	if err := c.ShouldBindJSON(&loginReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
-	var user User
-	if err := database.DB.Where("email = ?", loginReq.Email).First(&user).Error; err != nil {
+	user, err := h.users.GetByEmail(c.Request.Context(), loginReq.Email)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
@@ -162,15 +185,14 @@ func Login(c *gin.Context) {
 	})
 }
 
-func Register(c *gin.Context) {
+func (h *AuthHandler) Register(c *gin.Context) {
 	var registerReq auth.RegisterRequest
 	if err := c.ShouldBindJSON(&registerReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
-	var existingUser User
-	if err := database.DB.Where("email = ?", registerReq.Email).First(&existingUser).Error; err == nil {
+	if _, err := h.users.GetByEmail(c.Request.Context(), registerReq.Email); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
 		return
 	}
@@ -189,10 +211,11 @@ func Register(c *gin.Context) {
 		DisplayName: registerReq.DisplayName,
 	}
 
-	if err := database.DB.Create(&newUser).Error; err != nil {
+	if err := h.users.Create(c.Request.Context(), &newUser); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	userStatsChanged(c, newUser.ID)
 
 	token, err := auth.GenerateToken(int(newUser.ID), newUser.Email)
 	if err != nil {
@@ -213,7 +236,7 @@ func Register(c *gin.Context) {
 	})
 }
 
-func GetProfile(c *gin.Context) {
+func (h *AuthHandler) GetProfile(c *gin.Context) {
 
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -221,8 +244,8 @@ func GetProfile(c *gin.Context) {
 		return
 	}
 
-	var user User
-	if err := database.DB.First(&user, uint(userID.(int))).Error; err != nil {
+	user, err := h.users.Get(c.Request.Context(), uint(userID.(int)))
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
@@ -244,82 +267,76 @@ type PaginatedResponse struct {
 	TotalPages int    `json:"total_pages"`
 }
 
-func GetTasks(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
+// taskErrors are the task rule violations reported to clients as 400s.
+var taskErrors = map[error]string{
+	service.ErrParentNotFound:   "Parent task not found",
+	service.ErrAssigneeNotFound: "Assignee not found",
+	service.ErrOwnParent:        "Task cannot be its own parent",
+	service.ErrCircularParent:   "Circular dependency detected",
+	service.ErrHasSubtasks:      "Cannot delete task with subtasks",
+	service.ErrTitleRequired:    "Title is required",
+	service.ErrInvalidStatus:    "Invalid status",
+}
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
-	if page < 1 {
-		page = 1
+// respondTaskError answers with the status and message for err, using
+// fallback for unexpected errors.
+func respondTaskError(c *gin.Context, err error, fallback string) {
+	if errors.Is(err, service.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
 	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 50
+	for target, message := range taskErrors {
+		if errors.Is(err, target) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
 
-	sortBy := c.DefaultQuery("sort_by", "created_at")
-	sortOrder := c.DefaultQuery("sort_order", "desc")
-
-	validSortFields := map[string]bool{
-		"id":          true,
-		"title":       true,
-		"status":      true,
-		"created_at":  true,
-		"updated_at":  true,
-		"creator_id":  true,
-		"assignee_id": true,
-	}
+// TaskHandler serves the task endpoints.
+type TaskHandler struct {
+	tasks *service.TaskService
+}
 
-	if !validSortFields[sortBy] {
-		sortBy = "created_at"
-	}
+func NewTaskHandler(tasks *service.TaskService) *TaskHandler {
+	return &TaskHandler{tasks: tasks}
+}
 
-	if sortOrder != "asc" && sortOrder != "desc" {
-		sortOrder = "desc"
+func (h *TaskHandler) GetTasks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
 
-	var tasks []Task
-	var total int64
-
-	query := database.DB.Preload("Creator").Preload("Assignee").Preload("Subtasks").Where("deleted_at IS NULL")
-	countQuery := database.DB.Model(&Task{}).Where("deleted_at IS NULL")
-
-	myTasksOnly := c.Query("my_tasks") == "true"
-	if myTasksOnly {
-		query = query.Where("assignee_id = ?", uint(userID.(int)))
-		countQuery = countQuery.Where("assignee_id = ?", uint(userID.(int)))
+	query := service.TaskQuery{
+		SortBy:    c.DefaultQuery("sort_by", "created_at"),
+		SortOrder: c.DefaultQuery("sort_order", "desc"),
 	}
-
-	if err := countQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count tasks"})
-		return
+	query.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	query.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if c.Query("my_tasks") == "true" {
+		assigneeID := uint(userID.(int))
+		query.AssigneeID = &assigneeID
 	}
 
-	offset := (page - 1) * pageSize
-	orderClause := sortBy + " " + sortOrder
-
-	if err := query.Order(orderClause).Offset(offset).Limit(pageSize).Find(&tasks).Error; err != nil {
+	page, err := h.tasks.List(c.Request.Context(), query)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
 		return
 	}
 
-	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
-
-	response := PaginatedResponse{
-		Data:       tasks,
-		Total:      total,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalPages: totalPages,
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       page.Tasks,
+		Total:      page.Total,
+		Page:       page.Page,
+		PageSize:   page.PageSize,
+		TotalPages: page.TotalPages,
+	})
 }
 
-func GetTask(c *gin.Context) {
+func (h *TaskHandler) GetTask(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
@@ -327,16 +344,16 @@ func GetTask(c *gin.Context) {
 		return
 	}
 
-	var task Task
-	if err := database.DB.Preload("Creator").Preload("Assignee").Preload("Subtasks").Where("deleted_at IS NULL").First(&task, uint(id)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	task, err := h.tasks.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		respondTaskError(c, err, "Failed to fetch task")
 		return
 	}
 
 	c.JSON(http.StatusOK, task)
 }
 
-func CreateTask(c *gin.Context) {
+func (h *TaskHandler) CreateTask(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -349,48 +366,22 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
-	newTask.CreatorID = uint(userID.(int))
-
-	if newTask.ParentID != nil {
-		var parentTask Task
-		if err := database.DB.First(&parentTask, *newTask.ParentID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent task not found"})
-			return
-		}
-	}
-
-	if newTask.AssigneeID != nil {
-		var assignee User
-		if err := database.DB.First(&assignee, *newTask.AssigneeID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Assignee not found"})
-			return
-		}
-	}
-
-	if newTask.Status == "" {
-		newTask.Status = database.TaskStatusTodo
-	}
-
-	newTask.CreatedAt = time.Now()
-	newTask.UpdatedAt = time.Now()
-
-	if err := database.DB.Create(&newTask).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
-		return
-	}
-
-	if err := database.DB.Preload("Creator").Preload("Assignee").First(&newTask, newTask.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task relationships"})
+	if err := h.tasks.Create(c.Request.Context(), uint(userID.(int)), &newTask); err != nil {
+		respondTaskError(c, err, "Failed to create task")
 		return
 	}
-
-	// Broadcast task creation via Socket.IO
-	BroadcastTaskCreated(newTask)
+	wakeOutbox()
 
 	c.JSON(http.StatusCreated, newTask)
 }
 
-func UpdateTask(c *gin.Context) {
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
@@ -398,73 +389,29 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
-	var task Task
-	if err := database.DB.First(&task, uint(id)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
-		return
-	}
-
-	updatedTask := task
-
 	var updateReq TaskUpdateRequest
 	if err := c.ShouldBindJSON(&updateReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
 
-	if updateReq.Title != nil {
-		updatedTask.Title = *updateReq.Title
-	}
-	if updateReq.Description != nil {
-		updatedTask.Description = *updateReq.Description
-	}
-	if updateReq.Status != nil {
-		updatedTask.Status = *updateReq.Status
-	}
-	if updateReq.AssigneeID != nil {
-		updatedTask.AssigneeID = updateReq.AssigneeID
-	}
-	if updateReq.ParentID != nil {
-		if *updateReq.ParentID != 0 {
-			var parentTask Task
-			if err := database.DB.First(&parentTask, *updateReq.ParentID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent task not found"})
-				return
-			}
-			if *updateReq.ParentID == updatedTask.ID {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Task cannot be its own parent"})
-				return
-			}
-			if parentTask.ParentID != nil && *parentTask.ParentID == updatedTask.ID {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Circular dependency detected"})
-				return
-			}
-		}
-		updatedTask.ParentID = updateReq.ParentID
-	}
-	if updateReq.Unassigned {
-		updatedTask.AssigneeID = nil
-	}
-
-	updatedTask.UpdatedAt = time.Now()
-
-	if err := database.DB.Save(&updatedTask).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+	task, err := h.tasks.Update(c.Request.Context(), uint(userID.(int)), uint(id), updateReq)
+	if err != nil {
+		respondTaskError(c, err, "Failed to update task")
 		return
 	}
+	wakeOutbox()
 
-	if err := database.DB.Preload("Creator").Preload("Assignee").First(&updatedTask, updatedTask.ID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task relationships"})
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *TaskHandler) DeleteTask(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Broadcast task update via Socket.IO
-	BroadcastTaskUpdated(updatedTask)
-
-	c.JSON(http.StatusOK, updatedTask)
-}
-
-func DeleteTask(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
@@ -472,44 +419,16 @@ func DeleteTask(c *gin.Context) {
 		return
 	}
 
-	var task Task
-	if err := database.DB.First(&task, uint(id)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
-		return
-	}
-
-	var subtaskCount int64
-	if err := database.DB.Model(&Task{}).Where("parent_id = ?", uint(id)).Count(&subtaskCount).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check subtasks"})
-		return
-	}
-
-	if subtaskCount > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete task with subtasks"})
+	if err := h.tasks.Delete(c.Request.Context(), uint(userID.(int)), uint(id)); err != nil {
+		respondTaskError(c, err, "Failed to delete task")
 		return
 	}
-
-	// Hard Delete
-	// if err := database.DB.Delete(&Task{}, uint(id)).Error; err != nil {
-	// 	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
-	// 	return
-	// }
-
-	// Soft Delete
-	task.DeletedAt.Time = time.Now()
-	task.DeletedAt.Valid = true
-	if err := database.DB.Save(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task"})
-		return
-	}
-
-	// Broadcast task deletion via Socket.IO
-	BroadcastTaskDeleted(uint(id), task.AssigneeID)
+	wakeOutbox()
 
 	c.Status(http.StatusNoContent)
 }
 
-func GetSubtasks(c *gin.Context) {
+func (h *TaskHandler) GetSubtasks(c *gin.Context) {
 	idParam := c.Param("id")
 	parentID, err := strconv.ParseUint(idParam, 10, 32)
 	if err != nil {
@@ -517,8 +436,8 @@ func GetSubtasks(c *gin.Context) {
 		return
 	}
 
-	var subtasks []Task
-	if err := database.DB.Where("parent_id = ?", uint(parentID)).Find(&subtasks).Error; err != nil {
+	subtasks, err := h.tasks.Subtasks(c.Request.Context(), uint(parentID))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subtasks"})
 		return
 	}
@@ -526,6 +445,66 @@ func GetSubtasks(c *gin.Context) {
 	c.JSON(http.StatusOK, subtasks)
 }
 
+// taskHooks records the consequences of a task change: status history,
+// watchers, notifications, mentions and the outbox events the dispatcher
+// broadcasts once the transaction commits.
+type taskHooks struct{}
+
+// NewTaskHooks returns the repository.TaskHooks the task repository runs in
+// its write transactions.
+func NewTaskHooks() repository.TaskHooks {
+	return taskHooks{}
+}
+
+func (taskHooks) TaskCreated(tx *gorm.DB, actorID uint, task *Task) error {
+	if err := recordStatusChange(tx, actorID, *task, ""); err != nil {
+		return err
+	}
+	if err := watchTask(tx, task.ID, &task.CreatorID, task.AssigneeID); err != nil {
+		return err
+	}
+	if err := tx.Preload("Creator", publicUserFields).Preload("Assignee", publicUserFields).Preload("Watchers", publicUserFields).First(task, task.ID).Error; err != nil {
+		return err
+	}
+	if err := notifyTaskChange(tx, actorID, nil, *task); err != nil {
+		return err
+	}
+	if err := syncMentions(tx, actorID, *task); err != nil {
+		return err
+	}
+	return BroadcastTaskCreated(tx, *task)
+}
+
+func (taskHooks) TaskUpdated(tx *gorm.DB, actorID uint, before Task, task *Task) error {
+	if task.Status != before.Status {
+		if err := recordStatusChange(tx, actorID, *task, before.Status); err != nil {
+			return err
+		}
+	}
+	if err := watchTask(tx, task.ID, task.AssigneeID); err != nil {
+		return err
+	}
+	if err := tx.Preload("Creator", publicUserFields).Preload("Assignee", publicUserFields).Preload("Watchers", publicUserFields).First(task, task.ID).Error; err != nil {
+		return err
+	}
+	if err := notifyTaskChange(tx, actorID, &before, *task); err != nil {
+		return err
+	}
+	if task.Description != before.Description {
+		if err := syncMentions(tx, actorID, *task); err != nil {
+			return err
+		}
+	}
+	return BroadcastTaskUpdated(tx, *task, before.AssigneeID)
+}
+
+func (taskHooks) TaskDeleted(tx *gorm.DB, actorID uint, task Task) error {
+	if err := notifyTaskDeleted(tx, actorID, task); err != nil {
+		return err
+	}
+	return BroadcastTaskDeleted(tx, task)
+}
+
 type UserStats struct {
 	UserID         uint    `json:"user_id"`
 	Username       string  `json:"username"`
@@ -560,20 +539,9 @@ func GetStats(c *gin.Context) {
 		return
 	}
 
-	targetUserID := c.Query("user_id")
-
-	var userStats []UserStats
-	var overallStats TaskStats
-
-	database.DB.Model(&Task{}).Count(&overallStats.TotalTasks)
-	database.DB.Model(&Task{}).Where("status = ?", database.TaskStatusTodo).Count(&overallStats.TodoTasks)
-	database.DB.Model(&Task{}).Where("status = ?", database.TaskStatusInProgress).Count(&overallStats.InProgressTasks)
-	database.DB.Model(&Task{}).Where("status = ?", database.TaskStatusDone).Count(&overallStats.CompletedTasks)
-	database.DB.Model(&Task{}).Where("status = ?", database.TaskStatusCancelled).Count(&overallStats.CancelledTasks)
-	database.DB.Model(&Task{}).Where("assignee_id IS NULL").Count(&overallStats.UnassignedTasks)
-
-	if targetUserID != "" {
-
+	var target *User
+	var cacheKey uint
+	if targetUserID := c.Query("user_id"); targetUserID != "" {
 		targetID, err := strconv.ParseUint(targetUserID, 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
@@ -581,57 +549,117 @@ func GetStats(c *gin.Context) {
 		}
 
 		var user User
-		if err := database.DB.First(&user, uint(targetID)).Error; err != nil {
+		if err := dbFor(c).First(&user, uint(targetID)).Error; err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
+		target = &user
+		cacheKey = user.ID
+	}
 
-		var stats UserStats
-		stats.UserID = user.ID
-		stats.Username = user.Username
-		stats.DisplayName = user.DisplayName
+	if cached, ok := cachedStats(cacheKey); ok {
+		c.JSON(http.StatusOK, cached)
+		return
+	}
 
-		database.DB.Model(&Task{}).Where("assignee_id = ?", user.ID).Count(&stats.TotalTasks)
-		database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusTodo).Count(&stats.TodoTasks)
-		database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusInProgress).Count(&stats.InProgress)
-		database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusDone).Count(&stats.CompletedTasks)
-		database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusCancelled).Count(&stats.CancelledTasks)
+	response, err := computeStats(dbFor(c), target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute stats"})
+		return
+	}
+	storeStats(cacheKey, response)
 
-		if stats.TotalTasks > 0 {
-			stats.CompletionRate = float64(stats.CompletedTasks) / float64(stats.TotalTasks) * 100
-		}
+	c.JSON(http.StatusOK, response)
+}
 
-		userStats = append(userStats, stats)
-	} else {
+type statusCount struct {
+	AssigneeID *uint
+	Status     string
+	Count      int64
+}
 
-		var users []User
-		database.DB.Where("id IN (SELECT DISTINCT assignee_id FROM tasks WHERE assignee_id IS NOT NULL)").Find(&users)
+// computeStats builds the stats from a single grouped count of tasks by
+// assignee and status. With a target user only their stats are listed;
+// otherwise every user with assigned tasks is.
+func computeStats(db *gorm.DB, target *User) (StatsResponse, error) {
+	var rows []statusCount
+	if err := db.Model(&Task{}).
+		Select("assignee_id, status, COUNT(*) AS count").
+		Group("assignee_id, status").
+		Scan(&rows).Error; err != nil {
+		return StatsResponse{}, err
+	}
 
-		for _, user := range users {
-			var stats UserStats
-			stats.UserID = user.ID
-			stats.Username = user.Username
-			stats.DisplayName = user.DisplayName
+	var overallStats TaskStats
+	perUser := make(map[uint]*UserStats)
+	for _, row := range rows {
+		overallStats.TotalTasks += row.Count
+		switch row.Status {
+		case database.TaskStatusTodo:
+			overallStats.TodoTasks += row.Count
+		case database.TaskStatusInProgress:
+			overallStats.InProgressTasks += row.Count
+		case database.TaskStatusDone:
+			overallStats.CompletedTasks += row.Count
+		case database.TaskStatusCancelled:
+			overallStats.CancelledTasks += row.Count
+		}
 
-			database.DB.Model(&Task{}).Where("assignee_id = ?", user.ID).Count(&stats.TotalTasks)
-			database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusTodo).Count(&stats.TodoTasks)
-			database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusInProgress).Count(&stats.InProgress)
-			database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusDone).Count(&stats.CompletedTasks)
-			database.DB.Model(&Task{}).Where("assignee_id = ? AND status = ?", user.ID, database.TaskStatusCancelled).Count(&stats.CancelledTasks)
+		if row.AssigneeID == nil {
+			overallStats.UnassignedTasks += row.Count
+			continue
+		}
 
-			if stats.TotalTasks > 0 {
-				stats.CompletionRate = float64(stats.CompletedTasks) / float64(stats.TotalTasks) * 100
-			}
+		stats := perUser[*row.AssigneeID]
+		if stats == nil {
+			stats = &UserStats{UserID: *row.AssigneeID}
+			perUser[*row.AssigneeID] = stats
+		}
+		stats.TotalTasks += row.Count
+		switch row.Status {
+		case database.TaskStatusTodo:
+			stats.TodoTasks += row.Count
+		case database.TaskStatusInProgress:
+			stats.InProgress += row.Count
+		case database.TaskStatusDone:
+			stats.CompletedTasks += row.Count
+		case database.TaskStatusCancelled:
+			stats.CancelledTasks += row.Count
+		}
+	}
 
-			userStats = append(userStats, stats)
+	var users []User
+	if target != nil {
+		users = []User{*target}
+	} else if len(perUser) > 0 {
+		ids := make([]uint, 0, len(perUser))
+		for id := range perUser {
+			ids = append(ids, id)
+		}
+		if err := db.Order("id").Find(&users, ids).Error; err != nil {
+			return StatsResponse{}, err
 		}
 	}
 
-	response := StatsResponse{
+	userStats := []UserStats{}
+	for _, user := range users {
+		stats := UserStats{UserID: user.ID}
+		if counted := perUser[user.ID]; counted != nil {
+			stats = *counted
+		}
+		stats.Username = user.Username
+		stats.DisplayName = user.DisplayName
+
+		if stats.TotalTasks > 0 {
+			stats.CompletionRate = float64(stats.CompletedTasks) / float64(stats.TotalTasks) * 100
+		}
+
+		userStats = append(userStats, stats)
+	}
+
+	return StatsResponse{
 		OverallStats: overallStats,
 		UserStats:    userStats,
 		GeneratedAt:  time.Now(),
-	}
-
-	c.JSON(http.StatusOK, response)
+	}, nil
 }
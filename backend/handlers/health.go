@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"ziggler_backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How long a single readiness check may take.
+const readinessCheckTimeout = 2 * time.Second
+
+type CheckResult struct {
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs float64                `json:"duration_ms"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// readinessCheck reports on one dependency. It returns details to include
+// either way and an error if the dependency is not usable.
+type readinessCheck func(ctx context.Context) (map[string]interface{}, error)
+
+var readinessChecks = map[string]readinessCheck{
+	"database":   checkDatabase,
+	"migrations": checkMigrations,
+	"hub":        checkHub,
+}
+
+// Liveness reports that the process is up and serving HTTP. It checks no
+// dependencies, so orchestrators only restart the server when it is hung.
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness runs the dependency checks concurrently and answers 503 if any
+// fails, so load balancers stop routing to a replica that cannot serve
+// requests.
+func Readiness(c *gin.Context) {
+	response := ReadinessResponse{Status: "ready", Checks: make(map[string]CheckResult, len(readinessChecks))}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range readinessChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), readinessCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			details, err := check(ctx)
+
+			result := CheckResult{
+				Status:     "ok",
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:    details,
+			}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Checks[name] = result
+			if err != nil {
+				response.Status = "degraded"
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if response.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, response)
+}
+
+func checkDatabase(ctx context.Context) (map[string]interface{}, error) {
+	// SQLite keeps using a database file that was deleted, so check that
+	// it is still there.
+	if path := database.SQLiteFile(); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("database file: %w", err)
+		}
+	}
+
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	// A ping does not read the database, so a locked or corrupt file only
+	// shows up once a table is queried.
+	var users int64
+	if err := database.DB.WithContext(ctx).Model(&User{}).Count(&users).Error; err != nil {
+		return nil, err
+	}
+
+	stats := sqlDB.Stats()
+	return map[string]interface{}{
+		"open_connections": stats.OpenConnections,
+		"in_use":           stats.InUse,
+	}, nil
+}
+
+func checkMigrations(ctx context.Context) (map[string]interface{}, error) {
+	statuses, err := database.MigrationStatuses(database.DB.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var current uint
+	var pending []string
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%04d_%s", status.Version, status.Name))
+		} else if status.Version > current {
+			current = status.Version
+		}
+	}
+
+	details := map[string]interface{}{"version": current}
+	if len(pending) > 0 {
+		details["pending"] = pending
+		return details, fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return details, nil
+}
+
+// checkHub fails while the hub is shutting down or when the broadcast queue
+// is full (the broadcast loop is not keeping up). Outbox lag is reported but
+// never fails the check: the outbox is shared by every replica, so a stuck
+// dispatcher would take them all out of rotation at once. Alert on the
+// ziggler_outbox_lag_seconds metric instead.
+func checkHub(ctx context.Context) (map[string]interface{}, error) {
+	clientsMu.Lock()
+	connected := len(clients)
+	clientsMu.Unlock()
+
+	details := map[string]interface{}{
+		"clients":        connected,
+		"queue_depth":    len(broadcast),
+		"queue_capacity": cap(broadcast),
+	}
+
+	select {
+	case <-hubDone:
+		return details, fmt.Errorf("hub is shut down")
+	default:
+	}
+	if hubPubSub == nil {
+		return details, fmt.Errorf("hub is not initialized")
+	}
+	if len(broadcast) >= cap(broadcast) {
+		return details, fmt.Errorf("broadcast queue is full")
+	}
+
+	if lag, err := outboxLag(ctx); err == nil {
+		details["outbox_lag_seconds"] = lag.Seconds()
+	}
+	return details, nil
+}
+
+// outboxLag returns how long the oldest undelivered event has waited for
+// the dispatcher, or zero if none is waiting. Parked events are left out:
+// they are reported by the ziggler_outbox_failed_events metric instead.
+func outboxLag(ctx context.Context) (time.Duration, error) {
+	var oldest database.OutboxEvent
+	result := database.DB.WithContext(ctx).Select("created_at").Where("delivered_at IS NULL AND failed_at IS NULL").Order("id asc").Limit(1).Find(&oldest)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0, result.Error
+	}
+	return time.Since(oldest.CreatedAt), nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ziggler_backend/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Mention = database.Mention
+
+type MentionListResponse struct {
+	Data       []Mention `json:"data"`
+	Total      int64     `json:"total"`
+	Page       int       `json:"page"`
+	PageSize   int       `json:"page_size"`
+	TotalPages int       `json:"total_pages"`
+}
+
+// An @ only starts a mention at the beginning of the text or after a
+// character that cannot be part of a username, so emails do not match.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@.])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// parseMentions returns the distinct usernames mentioned in text.
+func parseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// Trailing punctuation belongs to the sentence, not the username.
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// syncMentions brings the task's mention records in line with its
+// description within tx and notifies users who are newly mentioned.
+func syncMentions(tx *gorm.DB, actorID uint, task Task) error {
+	var mentioned []User
+	if usernames := parseMentions(task.Description); len(usernames) > 0 {
+		if err := tx.Where("username IN ?", usernames).Find(&mentioned).Error; err != nil {
+			return err
+		}
+	}
+
+	var existing []Mention
+	if err := tx.Where("task_id = ?", task.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	already := make(map[uint]bool, len(existing))
+	for _, mention := range existing {
+		already[mention.UserID] = true
+	}
+
+	current := make(map[uint]bool, len(mentioned))
+	for _, user := range mentioned {
+		current[user.ID] = true
+		if already[user.ID] {
+			continue
+		}
+
+		if err := tx.Create(&Mention{TaskID: task.ID, UserID: user.ID, MentionedByID: actorID}).Error; err != nil {
+			return err
+		}
+		err := notify(tx, Notification{
+			UserID:  user.ID,
+			ActorID: &actorID,
+			TaskID:  &task.ID,
+			Type:    database.NotificationTypeMentioned,
+			Message: fmt.Sprintf("You were mentioned in \"%s\"", task.Title),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var removed []uint
+	for _, mention := range existing {
+		if !current[mention.UserID] {
+			removed = append(removed, mention.ID)
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Delete(&Mention{}, removed).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetMyMentions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	uid := uint(userID.(int))
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	// Mentions on deleted tasks are hidden.
+	liveTasks := "task_id IN (SELECT id FROM tasks WHERE deleted_at IS NULL)"
+
+	var total int64
+	if err := dbFor(c).Model(&Mention{}).Where("user_id = ?", uid).Where(liveTasks).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count mentions"})
+		return
+	}
+
+	var mentions []Mention
+	if err := dbFor(c).Where("user_id = ?", uid).Where(liveTasks).
+		Preload("Task").
+		Preload("MentionedBy", publicUserFields).
+		Order("created_at desc, id desc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&mentions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mentions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MentionListResponse{
+		Data:       mentions,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ziggler_backend/database"
+)
+
+func TestParseMentions(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		{"@alice please review", []string{"alice"}},
+		{"Ask @bob, then @alice.", []string{"bob", "alice"}},
+		{"@bob and @bob again", []string{"bob"}},
+		{"mail bob@example.com", nil},
+		{"(@first.last-name)", []string{"first.last-name"}},
+		{"no mentions", nil},
+	} {
+		if got := parseMentions(tc.text); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("parseMentions(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func myMentions(t *testing.T, token string) MentionListResponse {
+	t.Helper()
+	w := request(t, http.MethodGet, "/api/v1/users/me/mentions", token, nil)
+	expectStatus(t, w, http.StatusOK)
+	return decode[MentionListResponse](t, w)
+}
+
+func TestMentions(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{
+		"title":       "Review",
+		"description": "@bob can you look? cc @nobody",
+	})
+
+	mentions := myMentions(t, f.BobToken)
+	if mentions.Total != 1 || mentions.Data[0].TaskID != task.ID || mentions.Data[0].MentionedByID != f.Alice.ID {
+		t.Fatalf("Bob's mentions = %+v, want one on task %d by Alice", mentions.Data, task.ID)
+	}
+	if by := mentions.Data[0].MentionedBy; by == nil || by.Username != "alice" || by.Email != "" {
+		t.Errorf("mentioned_by = %+v, want Alice without her email", by)
+	}
+	list := listNotifications(t, f.BobToken, "")
+	if list.Total != 1 || list.Data[0].Type != database.NotificationTypeMentioned {
+		t.Errorf("Bob's notifications = %+v, want the mention", list.Data)
+	}
+
+	// Editing the description again neither duplicates the mention nor
+	// notifies Bob twice; dropping him removes it.
+	updateTask(t, f.AliceToken, task.ID, map[string]interface{}{"description": "@bob can you look now?"})
+	if got := myMentions(t, f.BobToken); got.Total != 1 {
+		t.Errorf("Bob has %d mentions after an edit, want 1", got.Total)
+	}
+	updateTask(t, f.AliceToken, task.ID, map[string]interface{}{"description": "Never mind, @admin"})
+	if got := myMentions(t, f.BobToken); got.Total != 0 {
+		t.Errorf("Bob has %d mentions after being dropped, want 0", got.Total)
+	}
+	mentioned := 0
+	for _, notification := range listNotifications(t, f.BobToken, "").Data {
+		if notification.Type == database.NotificationTypeMentioned {
+			mentioned++
+		}
+	}
+	if mentioned != 1 {
+		t.Errorf("Bob got %d mention notifications, want 1", mentioned)
+	}
+
+	// Mentions on deleted tasks are hidden.
+	if got := myMentions(t, f.AdminToken); got.Total != 1 {
+		t.Fatalf("Admin has %d mentions, want 1", got.Total)
+	}
+	w := request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", task.ID), f.AliceToken, nil)
+	expectStatus(t, w, http.StatusNoContent)
+	if got := myMentions(t, f.AdminToken); got.Total != 0 || len(got.Data) != 0 {
+		t.Errorf("Admin's mentions = %+v after deleting the task, want none", got)
+	}
+}
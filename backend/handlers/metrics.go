@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"ziggler_backend/database"
+	"ziggler_backend/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterMetrics exposes the hub and task gauges. The values are read at
+// scrape time, so nothing has to be updated as clients or tasks change.
+func RegisterMetrics() {
+	metrics.GaugeFunc("websocket_connected_clients", "Connected WebSocket and SSE clients on this replica.", func() float64 {
+		clientsMu.Lock()
+		defer clientsMu.Unlock()
+		return float64(len(clients))
+	})
+	metrics.GaugeFunc("broadcast_queue_depth", "Events waiting in the hub's broadcast queue.", func() float64 {
+		return float64(len(broadcast))
+	})
+	metrics.GaugeFunc("broadcast_queue_capacity", "Size of the hub's broadcast queue.", func() float64 {
+		return float64(cap(broadcast))
+	})
+	metrics.Register(taskCollector{})
+}
+
+var (
+	tasksDesc  = prometheus.NewDesc("ziggler_tasks", "Tasks by status, excluding deleted tasks.", []string{"status"}, nil)
+	outboxDesc = prometheus.NewDesc("ziggler_outbox_pending_events", "Committed events not yet delivered by the outbox dispatcher.", nil, nil)
+	failedDesc = prometheus.NewDesc("ziggler_outbox_failed_events", "Outbox events parked after too many failed deliveries.", nil, nil)
+	lagDesc    = prometheus.NewDesc("ziggler_outbox_lag_seconds", "How long the oldest undelivered outbox event has waited, or 0.", nil, nil)
+)
+
+// taskCollector reports the business gauges from the database on each
+// scrape.
+type taskCollector struct{}
+
+func (taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tasksDesc
+	ch <- outboxDesc
+	ch <- failedDesc
+	ch <- lagDesc
+}
+
+func (taskCollector) Collect(ch chan<- prometheus.Metric) {
+	var rows []statusCount
+	if err := database.DB.Model(&Task{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		slog.Error("Failed to collect task metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(tasksDesc, err)
+	} else {
+		counts := make(map[string]int64, len(taskStatuses))
+		for _, row := range rows {
+			counts[row.Status] += row.Count
+		}
+		for _, status := range taskStatuses {
+			ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(counts[status]), status)
+		}
+	}
+
+	var pending, failed int64
+	err := database.DB.Model(&database.OutboxEvent{}).Where("delivered_at IS NULL AND failed_at IS NULL").Count(&pending).Error
+	if err == nil {
+		err = database.DB.Model(&database.OutboxEvent{}).Where("failed_at IS NOT NULL").Count(&failed).Error
+	}
+	var lag time.Duration
+	if err == nil {
+		lag, err = outboxLag(context.Background())
+	}
+	if err != nil {
+		slog.Error("Failed to collect outbox metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(outboxDesc, err)
+		ch <- prometheus.NewInvalidMetric(failedDesc, err)
+		ch <- prometheus.NewInvalidMetric(lagDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(outboxDesc, prometheus.GaugeValue, float64(pending))
+	ch <- prometheus.MustNewConstMetric(failedDesc, prometheus.GaugeValue, float64(failed))
+	ch <- prometheus.MustNewConstMetric(lagDesc, prometheus.GaugeValue, lag.Seconds())
+}
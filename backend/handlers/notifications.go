@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ziggler_backend/database"
+	"ziggler_backend/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Notification = database.Notification
+
+type NotificationListResponse struct {
+	Data        []Notification `json:"data"`
+	Total       int64          `json:"total"`
+	UnreadCount int64          `json:"unread_count"`
+	Page        int            `json:"page"`
+	PageSize    int            `json:"page_size"`
+	TotalPages  int            `json:"total_pages"`
+}
+
+// UserTopic is the private topic every authenticated connection of a user
+// is subscribed to. Clients cannot subscribe to it explicitly.
+func UserTopic(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+var publicUserFields = repository.PublicUserFields
+
+// notify stores a notification within tx and queues it for delivery to the
+// recipient's connections, and assignments also by email. Users are never
+// notified of their own actions.
+func notify(tx *gorm.DB, notification Notification) error {
+	if notification.ActorID != nil && *notification.ActorID == notification.UserID {
+		return nil
+	}
+
+	if err := tx.Create(&notification).Error; err != nil {
+		return err
+	}
+	if notification.Type == database.NotificationTypeAssigned {
+		if err := enqueueEmail(tx, notification); err != nil {
+			return err
+		}
+	}
+	if err := tx.Preload("Actor", publicUserFields).First(&notification, notification.ID).Error; err != nil {
+		return err
+	}
+
+	return enqueueEvent(tx, WSMessage{
+		Type:    "notification_created",
+		Payload: notification,
+		topics:  []string{UserTopic(notification.UserID)},
+	})
+}
+
+// notifyTaskChange generates the notifications for a created (before is nil)
+// or updated task: the new assignee is told about the assignment, the
+// creator about status changes and any other watcher about what changed.
+func notifyTaskChange(tx *gorm.DB, actorID uint, before *Task, after Task) error {
+	var actor User
+	if err := tx.First(&actor, actorID).Error; err != nil {
+		return err
+	}
+
+	actorName := actor.DisplayName
+	if actorName == "" {
+		actorName = actor.Username
+	}
+
+	notified := map[uint]bool{}
+
+	assigneeChanged := after.AssigneeID != nil &&
+		(before == nil || before.AssigneeID == nil || *before.AssigneeID != *after.AssigneeID)
+	if assigneeChanged {
+		err := notify(tx, Notification{
+			UserID:  *after.AssigneeID,
+			ActorID: &actor.ID,
+			TaskID:  &after.ID,
+			Type:    database.NotificationTypeAssigned,
+			Message: fmt.Sprintf("%s assigned you to \"%s\"", actorName, after.Title),
+		})
+		if err != nil {
+			return err
+		}
+		notified[*after.AssigneeID] = true
+	}
+
+	if before != nil && before.Status != after.Status {
+		err := notify(tx, Notification{
+			UserID:  after.CreatorID,
+			ActorID: &actor.ID,
+			TaskID:  &after.ID,
+			Type:    database.NotificationTypeStatusChanged,
+			Message: fmt.Sprintf("%s moved \"%s\" from %s to %s", actorName, after.Title, before.Status, after.Status),
+		})
+		if err != nil {
+			return err
+		}
+		notified[after.CreatorID] = true
+	}
+
+	if before == nil {
+		return nil
+	}
+	message := describeTaskChange(actorName, *before, after)
+	if message == "" {
+		return nil
+	}
+	return notifyWatchers(tx, actor.ID, after, database.NotificationTypeTaskUpdated, message, notified)
+}
+
+func unreadNotificationCount(userID uint) (int64, error) {
+	var count int64
+	err := database.DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// publishUnreadCount tells the user's other open tabs that notifications
+// were read so their badges stay in sync.
+func publishUnreadCount(userID uint) {
+	count, err := unreadNotificationCount(userID)
+	if err != nil {
+		return
+	}
+	publish(WSMessage{
+		Type: "notifications_read",
+		Payload: map[string]interface{}{
+			"unread_count": count,
+		},
+		topics:    []string{UserTopic(userID)},
+		ephemeral: true,
+	})
+}
+
+func GetNotifications(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	uid := uint(userID.(int))
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	unreadOnly := c.Query("unread") == "true"
+	listQuery := func() *gorm.DB {
+		query := dbFor(c).Model(&Notification{}).Where("user_id = ?", uid)
+		if unreadOnly {
+			query = query.Where("read_at IS NULL")
+		}
+		return query
+	}
+
+	var total int64
+	if err := listQuery().Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	var notifications []Notification
+	if err := listQuery().Preload("Actor", publicUserFields).Preload("Task").Order("created_at desc, id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	unread, err := unreadNotificationCount(uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, NotificationListResponse{
+		Data:        notifications,
+		Total:       total,
+		UnreadCount: unread,
+		Page:        page,
+		PageSize:    pageSize,
+		TotalPages:  int((total + int64(pageSize) - 1) / int64(pageSize)),
+	})
+}
+
+func GetUnreadNotificationCount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	count, err := unreadNotificationCount(uint(userID.(int)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+func MarkNotificationRead(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	uid := uint(userID.(int))
+
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	var notification Notification
+	if err := dbFor(c).Where("user_id = ?", uid).First(&notification, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := dbFor(c).Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+		notification.ReadAt = &now
+		publishUnreadCount(uid)
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	uid := uint(userID.(int))
+
+	result := dbFor(c).Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", uid).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		publishUnreadCount(uid)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ziggler_backend/database"
+)
+
+func listNotifications(t *testing.T, token, query string) NotificationListResponse {
+	t.Helper()
+	w := request(t, http.MethodGet, "/api/v1/notifications"+query, token, nil)
+	expectStatus(t, w, http.StatusOK)
+	return decode[NotificationListResponse](t, w)
+}
+
+func unreadCount(t *testing.T, token string) int64 {
+	t.Helper()
+	w := request(t, http.MethodGet, "/api/v1/notifications/unread-count", token, nil)
+	expectStatus(t, w, http.StatusOK)
+	return decode[struct {
+		UnreadCount int64 `json:"unread_count"`
+	}](t, w).UnreadCount
+}
+
+func TestNotifications(t *testing.T) {
+	f := setup(t)
+	first := createTask(t, f.AliceToken, map[string]interface{}{"title": "First", "assignee_id": f.Bob.ID})
+	second := createTask(t, f.AliceToken, map[string]interface{}{"title": "Second", "assignee_id": f.Bob.ID})
+
+	list := listNotifications(t, f.BobToken, "")
+	if list.Total != 2 || list.UnreadCount != 2 || len(list.Data) != 2 {
+		t.Fatalf("notifications = %+v, want 2 unread", list)
+	}
+	latest := list.Data[0]
+	if latest.Type != database.NotificationTypeAssigned || latest.TaskID == nil || *latest.TaskID != second.ID {
+		t.Errorf("newest notification = %+v, want the assignment to Second", latest)
+	}
+	if latest.Actor == nil || latest.Actor.ID != f.Alice.ID || latest.Actor.Email != "" {
+		t.Errorf("actor = %+v, want Alice without her email", latest.Actor)
+	}
+	if want := `Alice assigned you to "Second"`; latest.Message != want {
+		t.Errorf("message = %q, want %q", latest.Message, want)
+	}
+
+	// Alice assigned the tasks herself, so she was not notified.
+	if got := listNotifications(t, f.AliceToken, ""); got.Total != 0 {
+		t.Errorf("Alice has %d notifications, want none", got.Total)
+	}
+
+	t.Run("mark read", func(t *testing.T) {
+		w := request(t, http.MethodPut, fmt.Sprintf("/api/v1/notifications/%d/read", latest.ID), f.BobToken, nil)
+		expectStatus(t, w, http.StatusOK)
+		if read := decode[Notification](t, w); read.ReadAt == nil {
+			t.Error("read_at not set")
+		}
+		if got := unreadCount(t, f.BobToken); got != 1 {
+			t.Errorf("unread count = %d, want 1", got)
+		}
+
+		unread := listNotifications(t, f.BobToken, "?unread=true")
+		if unread.Total != 1 || *unread.Data[0].TaskID != first.ID {
+			t.Errorf("unread notifications = %+v, want only the one about First", unread)
+		}
+	})
+
+	t.Run("other users' notifications", func(t *testing.T) {
+		w := request(t, http.MethodPut, fmt.Sprintf("/api/v1/notifications/%d/read", latest.ID), f.AliceToken, nil)
+		expectStatus(t, w, http.StatusNotFound)
+
+		w = request(t, http.MethodPut, "/api/v1/notifications/abc/read", f.BobToken, nil)
+		expectStatus(t, w, http.StatusBadRequest)
+	})
+
+	t.Run("mark all read", func(t *testing.T) {
+		w := request(t, http.MethodPut, "/api/v1/notifications/read-all", f.BobToken, nil)
+		expectStatus(t, w, http.StatusOK)
+		if updated := decode[map[string]int64](t, w)["updated"]; updated != 1 {
+			t.Errorf("updated = %d, want 1", updated)
+		}
+		if got := unreadCount(t, f.BobToken); got != 0 {
+			t.Errorf("unread count = %d, want 0", got)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		page := listNotifications(t, f.BobToken, "?page=2&page_size=1")
+		if page.Total != 2 || page.TotalPages != 2 || len(page.Data) != 1 || *page.Data[0].TaskID != first.ID {
+			t.Errorf("page 2 = %+v, want the notification about First", page)
+		}
+	})
+}
+
+func TestNotificationsReadElsewhereReachOtherTabs(t *testing.T) {
+	f := setup(t)
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "For Bob", "assignee_id": f.Bob.ID})
+	conn := dialWebSocket(t, f.BobToken)
+
+	w := request(t, http.MethodPut, "/api/v1/notifications/read-all", f.BobToken, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	event := expectEvent(t, conn, "notifications_read")
+	if got := string(event.Payload); got != `{"unread_count":0}` {
+		t.Errorf("notifications_read payload = %s, want an unread count of 0", got)
+	}
+}
+
+func TestStatusChangeNotifiesCreator(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Report", "assignee_id": f.Bob.ID})
+
+	updateTask(t, f.BobToken, task.ID, map[string]interface{}{"status": "in_progress"})
+
+	list := listNotifications(t, f.AliceToken, "")
+	if list.Total != 1 || list.Data[0].Type != database.NotificationTypeStatusChanged {
+		t.Fatalf("Alice's notifications = %+v, want one status change", list.Data)
+	}
+	if want := `Bob moved "Report" from todo to in_progress`; list.Data[0].Message != want {
+		t.Errorf("message = %q, want %q", list.Data[0].Message, want)
+	}
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"ziggler_backend/database"
+	"ziggler_backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"gorm.io/gorm"
+)
+
+const (
+	// How often the dispatcher polls for undelivered events when it has not
+	// been woken by a commit.
+	outboxPollInterval = time.Second
+
+	// How long the dispatcher lease lasts. Only the replica holding it
+	// delivers events; another one takes over once it lapses.
+	outboxLease = 30 * time.Second
+
+	outboxBatchSize = 100
+
+	// A failed event is retried after outboxPollInterval, doubling each
+	// time, and parked with failed_at set after this many attempts so it no
+	// longer holds back the events behind it.
+	outboxMaxAttempts = 5
+
+	// Delivered events are kept this long for inspection, then pruned.
+	outboxRetention = 24 * time.Hour
+)
+
+// EventSink receives every committed outbox event. Sinks must tolerate the
+// same event more than once, since a failing sink causes a retry for all.
+type EventSink func(ctx context.Context, msg WSMessage) error
+
+var (
+	sinksMu    sync.Mutex
+	eventSinks = []EventSink{hubSink}
+
+	outboxWake = make(chan struct{}, 1)
+)
+
+// RegisterEventSink adds a sink that is fed every outbox event after the
+// WebSocket hub.
+func RegisterEventSink(sink EventSink) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	eventSinks = append(eventSinks, sink)
+}
+
+// enqueueEvent writes msg to the outbox as part of tx. It is published by
+// the dispatcher only once tx commits, continuing the trace of tx's context.
+func enqueueEvent(tx *gorm.DB, msg WSMessage) error {
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&database.OutboxEvent{
+		Type:        msg.Type,
+		Topics:      strings.Join(msg.topics, ","),
+		Payload:     string(payload),
+		TraceParent: tracing.Inject(tx.Statement.Context),
+	}).Error
+}
+
+// wakeOutbox tells the dispatcher that new events were committed so they go
+// out without waiting for the next poll, and the email sender likewise,
+// since a commit may also have queued emails.
+func wakeOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+	wakeEmails()
+}
+
+func StartOutboxDispatcher() {
+	hubWorkers.Add(1)
+	go dispatchOutbox()
+	slog.Info("Outbox dispatcher started")
+}
+
+// dispatchOutbox delivers events while this replica holds the dispatcher
+// lease. A single dispatcher keeps events in commit order across replicas.
+func dispatchOutbox() {
+	defer hubWorkers.Done()
+	defer releaseDispatcher()
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-outboxWake:
+		case <-hubDone:
+			return
+		}
+
+		leaseUntil, ok := renewDispatcher()
+		if !ok {
+			continue
+		}
+		for dispatchBatch(&leaseUntil) == outboxBatchSize {
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			database.DB.Where("delivered_at < ?", time.Now().Add(-outboxRetention)).Delete(&database.OutboxEvent{})
+			lastPrune = time.Now()
+		}
+	}
+}
+
+// renewDispatcher takes or extends the dispatcher lease and returns when it
+// expires, or false if another replica holds it.
+func renewDispatcher() (time.Time, bool) {
+	now := time.Now()
+	leaseUntil := now.Add(outboxLease)
+	result := database.DB.Model(&database.OutboxDispatcher{}).
+		Where("id = ? AND (owner = ? OR locked_until IS NULL OR locked_until < ?)", 1, replicaID, now).
+		Updates(map[string]interface{}{"owner": replicaID, "locked_until": leaseUntil})
+	if result.Error != nil {
+		slog.Error("Failed to take the outbox dispatcher lease", "error", result.Error)
+		return time.Time{}, false
+	}
+	return leaseUntil, result.RowsAffected == 1
+}
+
+// releaseDispatcher gives up the lease so another replica can take over
+// without waiting for it to lapse.
+func releaseDispatcher() {
+	database.DB.Model(&database.OutboxDispatcher{}).
+		Where("id = ? AND owner = ?", 1, replicaID).
+		Update("locked_until", nil)
+}
+
+// dispatchBatch delivers up to outboxBatchSize pending events in order and
+// returns how many it delivered or parked. It stops at an event waiting to
+// be retried, so later events never overtake it, and when the lease can no
+// longer be renewed.
+func dispatchBatch(leaseUntil *time.Time) int {
+	var pending []database.OutboxEvent
+	err := database.DB.
+		Where("delivered_at IS NULL AND failed_at IS NULL").
+		Order("id asc").
+		Limit(outboxBatchSize).
+		Find(&pending).Error
+	if err != nil {
+		slog.Error("Failed to load outbox events", "error", err)
+		return 0
+	}
+
+	for i, event := range pending {
+		if event.LockedUntil != nil && event.LockedUntil.After(time.Now()) {
+			return i
+		}
+		// Delivery times out after half a lease, so renewing with less
+		// than that left could let the lease lapse mid-delivery.
+		if time.Until(*leaseUntil) < outboxLease/2 {
+			renewed, ok := renewDispatcher()
+			if !ok {
+				return i
+			}
+			*leaseUntil = renewed
+		}
+
+		attempts := event.Attempts + 1
+		if err := deliverOutboxEvent(event); err != nil {
+			updates := map[string]interface{}{
+				"attempts":   attempts,
+				"last_error": err.Error(),
+			}
+			if attempts >= outboxMaxAttempts {
+				slog.Error("Parking outbox event after repeated failures", "event_id", event.ID, "type", event.Type, "attempts", attempts, "error", err)
+				updates["failed_at"] = time.Now()
+				updates["locked_until"] = nil
+				database.DB.Model(&event).Updates(updates)
+				continue
+			}
+
+			slog.Error("Failed to deliver outbox event", "event_id", event.ID, "type", event.Type, "attempts", attempts, "error", err)
+			updates["locked_until"] = time.Now().Add(outboxPollInterval << (attempts - 1))
+			database.DB.Model(&event).Updates(updates)
+			return i
+		}
+
+		database.DB.Model(&event).Updates(map[string]interface{}{
+			"attempts":     attempts,
+			"delivered_at": time.Now(),
+			"locked_until": nil,
+		})
+	}
+
+	return len(pending)
+}
+
+func deliverOutboxEvent(event database.OutboxEvent) (err error) {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), event.TraceParent), "outbox.deliver",
+		attribute.Int64("outbox.event_id", int64(event.ID)),
+		attribute.String("event.type", event.Type),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var topics []string
+	if event.Topics != "" {
+		topics = strings.Split(event.Topics, ",")
+	}
+	msg := WSMessage{
+		Type:    event.Type,
+		Payload: json.RawMessage(event.Payload),
+		topics:  topics,
+		ctx:     ctx,
+	}
+
+	sinksMu.Lock()
+	sinks := append([]EventSink(nil), eventSinks...)
+	sinksMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, outboxLease/2)
+	defer cancel()
+
+	for _, sink := range sinks {
+		if err := sink(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hubSink logs an event, which gives it its sequence number, and publishes
+// it to every replica before the next event is dispatched. There is only
+// one dispatcher at a time, so sequence numbers are published in order. An
+// event that could not be logged is retried; once logged it is not failed
+// again, so a retry never logs it twice.
+func hubSink(ctx context.Context, msg WSMessage) error {
+	select {
+	case <-hubDone:
+		return errors.New("hub is shut down")
+	default:
+	}
+	msg.ctx = ctx
+	return broadcastMessage(msg)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ziggler_backend/database"
+	"ziggler_backend/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
+)
+
+var (
+	failingSinkOnce sync.Once
+	failingMu       sync.Mutex
+	failingTypes    = make(map[string]bool)
+)
+
+// failEvents makes delivery fail for events of the given types until the
+// test ends.
+func failEvents(t *testing.T, types ...string) {
+	failingSinkOnce.Do(func() {
+		RegisterEventSink(func(ctx context.Context, msg WSMessage) error {
+			failingMu.Lock()
+			defer failingMu.Unlock()
+			if failingTypes[msg.Type] {
+				return errors.New("sink unavailable")
+			}
+			return nil
+		})
+	})
+
+	failingMu.Lock()
+	for _, eventType := range types {
+		failingTypes[eventType] = true
+	}
+	failingMu.Unlock()
+	t.Cleanup(func() { recoverEvents(types...) })
+}
+
+func recoverEvents(types ...string) {
+	failingMu.Lock()
+	defer failingMu.Unlock()
+	for _, eventType := range types {
+		delete(failingTypes, eventType)
+	}
+}
+
+// enqueueTestEvents commits one outbox event per type, in order, and wakes
+// the dispatcher.
+func enqueueTestEvents(t *testing.T, types ...string) []database.OutboxEvent {
+	t.Helper()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, eventType := range types {
+			if err := enqueueEvent(tx, WSMessage{Type: eventType, Payload: map[string]string{}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wakeOutbox()
+
+	var events []database.OutboxEvent
+	database.DB.Where("type IN ?", types).Order("id asc").Find(&events)
+	return events
+}
+
+func reloadEvent(t *testing.T, id uint) database.OutboxEvent {
+	t.Helper()
+	var event database.OutboxEvent
+	if err := database.DB.First(&event, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestOutboxRetriesInOrder(t *testing.T) {
+	setup(t)
+	failEvents(t, "test_first")
+	events := enqueueTestEvents(t, "test_first", "test_second")
+	first, second := events[0].ID, events[1].ID
+
+	eventually(t, "the first delivery to fail", func() bool {
+		return reloadEvent(t, first).Attempts == 1
+	})
+	failed := reloadEvent(t, first)
+	if failed.LockedUntil == nil || failed.LastError != "sink unavailable" {
+		t.Errorf("failed event = %+v, want a retry time and the error", failed)
+	}
+	// The second event waits behind the first.
+	if got := reloadEvent(t, second); got.DeliveredAt != nil || got.Attempts != 0 {
+		t.Errorf("second event = %+v, want it held back", got)
+	}
+
+	recoverEvents("test_first")
+	eventually(t, "both events to be delivered", func() bool {
+		return reloadEvent(t, second).DeliveredAt != nil
+	})
+	if a, b := reloadEvent(t, first), reloadEvent(t, second); a.DeliveredAt == nil || a.DeliveredAt.After(*b.DeliveredAt) {
+		t.Errorf("first delivered at %v, second at %v, want first before second", a.DeliveredAt, b.DeliveredAt)
+	}
+}
+
+func TestOutboxParksEventAfterMaxAttempts(t *testing.T) {
+	setup(t)
+	failEvents(t, "test_poison")
+
+	// Start one attempt short of the cap, so the next failure parks it.
+	events := enqueueTestEvents(t, "test_poison", "test_after")
+	database.DB.Model(&events[0]).Update("attempts", outboxMaxAttempts-1)
+	wakeOutbox()
+
+	eventually(t, "the event behind the parked one to be delivered", func() bool {
+		return reloadEvent(t, events[1].ID).DeliveredAt != nil
+	})
+	parked := reloadEvent(t, events[0].ID)
+	if parked.FailedAt == nil || parked.DeliveredAt != nil || parked.Attempts != outboxMaxAttempts {
+		t.Errorf("poison event = %+v, want it parked after %d attempts", parked, outboxMaxAttempts)
+	}
+
+	// Parked events do not count as lag.
+	database.DB.Model(&parked).Update("created_at", time.Now().Add(-time.Hour))
+	if lag, err := outboxLag(context.Background()); err != nil || lag != 0 {
+		t.Errorf("outboxLag = %v, %v with only a parked event, want 0", lag, err)
+	}
+}
+
+func TestOutboxLagDoesNotFailReadiness(t *testing.T) {
+	setup(t)
+	failEvents(t, "test_stuck")
+
+	events := enqueueTestEvents(t, "test_stuck")
+	eventually(t, "a failed delivery", func() bool { return reloadEvent(t, events[0].ID).Attempts > 0 })
+	database.DB.Model(&events[0]).Update("created_at", time.Now().Add(-time.Hour))
+
+	// The outbox is shared by every replica, so its lag is only reported.
+	details, err := checkHub(context.Background())
+	if err != nil {
+		t.Errorf("checkHub = %v with a stuck event, want ready", err)
+	}
+	if lag, _ := details["outbox_lag_seconds"].(float64); lag < time.Hour.Seconds() {
+		t.Errorf("outbox_lag_seconds = %v, want at least an hour", details["outbox_lag_seconds"])
+	}
+}
+
+func TestOutboxWaitsForDispatcherLease(t *testing.T) {
+	setup(t)
+	database.DB.Model(&database.OutboxDispatcher{}).Where("id = ?", 1).
+		Updates(map[string]interface{}{"owner": "other-replica", "locked_until": time.Now().Add(time.Minute)})
+	t.Cleanup(func() {
+		database.DB.Model(&database.OutboxDispatcher{}).Where("id = ?", 1).Update("locked_until", nil)
+	})
+
+	events := enqueueTestEvents(t, "test_leased")
+	time.Sleep(2 * outboxPollInterval)
+	if got := reloadEvent(t, events[0].ID); got.DeliveredAt != nil {
+		t.Fatal("event delivered while another replica held the lease")
+	}
+
+	// Once the other replica's lease lapses this one takes over.
+	database.DB.Model(&database.OutboxDispatcher{}).Where("id = ?", 1).Update("locked_until", time.Now().Add(-time.Second))
+	eventually(t, "the event to be delivered", func() bool {
+		return reloadEvent(t, events[0].ID).DeliveredAt != nil
+	})
+}
+
+// recordSpans installs a tracer provider that records every span until the
+// test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := tracing.NewProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+// endedSpan returns the first recorded span with the given name for which
+// match returns true.
+func endedSpan(t *testing.T, recorder *tracetest.SpanRecorder, name string, match func(sdktrace.ReadOnlySpan) bool) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range recorder.Ended() {
+		if span.Name() == name && match(span) {
+			return span
+		}
+	}
+	t.Fatalf("no %s span recorded", name)
+	return nil
+}
+
+func TestOutboxContinuesRequestTrace(t *testing.T) {
+	f := setup(t)
+	recorder := recordSpans(t)
+
+	createTask(t, f.AdminToken, map[string]interface{}{"title": "Traced"})
+	eventually(t, "the outbox to drain", outboxDrained)
+
+	server := endedSpan(t, recorder, "POST /api/v1/tasks", func(sdktrace.ReadOnlySpan) bool { return true })
+	deliver := endedSpan(t, recorder, "outbox.deliver", func(span sdktrace.ReadOnlySpan) bool {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "event.type" && attr.Value.AsString() == "task_created" {
+				return true
+			}
+		}
+		return false
+	})
+
+	// The event's TraceParent links the dispatch to the request that
+	// caused it, even though it runs later on the dispatcher goroutine.
+	if deliver.SpanContext().TraceID() != server.SpanContext().TraceID() {
+		t.Errorf("outbox.deliver trace = %s, want the request's %s", deliver.SpanContext().TraceID(), server.SpanContext().TraceID())
+	}
+	if deliver.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("outbox.deliver parent = %s, want the request span %s", deliver.Parent().SpanID(), server.SpanContext().SpanID())
+	}
+	if !deliver.Parent().IsRemote() {
+		t.Error("outbox.deliver parent is local, want it restored from the event's TraceParent")
+	}
+
+	broadcast := endedSpan(t, recorder, "hub.broadcast", func(span sdktrace.ReadOnlySpan) bool {
+		return span.Parent().SpanID() == deliver.SpanContext().SpanID()
+	})
+	if broadcast.SpanContext().TraceID() != server.SpanContext().TraceID() {
+		t.Error("hub.broadcast is not in the request's trace")
+	}
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"ziggler_backend/database"
+	"ziggler_backend/pubsub"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	PresenceViewing = "viewing"
+	PresenceEditing = "editing"
+
+	// TopicPresence receives user_presence messages whenever a user's first
+	// connection opens or last connection closes.
+	TopicPresence = "presence"
+
+	// A viewing/editing marker expires unless the client repeats it within
+	// this window, so abandoned tabs do not keep a task locked forever.
+	presenceTTL = 90 * time.Second
+)
+
+type presenceEntry struct {
+	userID    int
+	taskID    uint
+	state     string
+	expiresAt time.Time
+}
+
+type PresenceUser struct {
+	UserID      uint   `json:"user_id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	State       string `json:"state"`
+}
+
+var (
+	presenceMu      sync.Mutex
+	userConnections = make(map[int]map[*hubClient]bool)
+	presence        = make(map[*hubClient]*presenceEntry)
+
+	// presenceStore shares this replica's connections with the others,
+	// under replicaID. saveMu keeps snapshots from being saved out of order.
+	presenceStore pubsub.Presence
+	saveMu        sync.Mutex
+)
+
+// presenceSnapshot is what one replica knows: the users connected to it and,
+// per task, the state of each user on the task.
+type presenceSnapshot struct {
+	Users []uint                   `json:"users"`
+	Tasks map[uint]map[uint]string `json:"tasks"`
+}
+
+// localPresence returns this replica's snapshot.
+func localPresence() presenceSnapshot {
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+
+	snapshot := presenceSnapshot{Tasks: make(map[uint]map[uint]string)}
+	for userID := range userConnections {
+		snapshot.Users = append(snapshot.Users, uint(userID))
+	}
+	for _, entry := range presence {
+		states := snapshot.Tasks[entry.taskID]
+		if states == nil {
+			states = make(map[uint]string)
+			snapshot.Tasks[entry.taskID] = states
+		}
+		id := uint(entry.userID)
+		if states[id] != PresenceEditing {
+			states[id] = entry.state
+		}
+	}
+	return snapshot
+}
+
+// savePresence publishes this replica's snapshot to the other replicas. It
+// also refreshes the snapshot's TTL, so the sweeper calls it periodically.
+func savePresence() {
+	if !presenceShared() {
+		return
+	}
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	data, _ := json.Marshal(localPresence())
+	ctx, cancel := context.WithTimeout(context.Background(), wsWriteWait)
+	defer cancel()
+	if err := presenceStore.Save(ctx, replicaID, data, presenceTTL); err != nil {
+		slog.Warn("Failed to save presence", "error", err)
+	}
+}
+
+// presenceShared reports whether the presence store is in use. It stops
+// being used once the hub shuts down, which removes this replica's snapshot.
+func presenceShared() bool {
+	select {
+	case <-hubDone:
+		return false
+	default:
+		return presenceStore != nil
+	}
+}
+
+// otherPresence returns the snapshots the other live replicas have saved.
+// If the store is unavailable it returns none, so this replica falls back
+// to its own view.
+func otherPresence() []presenceSnapshot {
+	if !presenceShared() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wsWriteWait)
+	defer cancel()
+	stored, err := presenceStore.Load(ctx)
+	if err != nil {
+		slog.Warn("Failed to load presence, using this replica only", "error", err)
+		return nil
+	}
+
+	var snapshots []presenceSnapshot
+	for replica, data := range stored {
+		if replica == replicaID {
+			continue
+		}
+		var snapshot presenceSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			slog.Warn("Failed to decode presence", "replica", replica, "error", err)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// onlineElsewhere reports whether the user is connected to another replica.
+func onlineElsewhere(userID int) bool {
+	for _, snapshot := range otherPresence() {
+		for _, id := range snapshot.Users {
+			if id == uint(userID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// trackConnection records an authenticated connection for the user and
+// announces them as online if it is their first on any replica.
+func trackConnection(client *hubClient, userID int) {
+	presenceMu.Lock()
+	conns := userConnections[userID]
+	if conns == nil {
+		conns = make(map[*hubClient]bool)
+		userConnections[userID] = conns
+	}
+	conns[client] = true
+	first := len(conns) == 1
+	presenceMu.Unlock()
+
+	if !first {
+		return
+	}
+	// Check before saving, so that of two replicas connecting the user at
+	// once at least one announces them. Going offline checks after saving
+	// for the same reason.
+	elsewhere := onlineElsewhere(userID)
+	savePresence()
+	if !elsewhere {
+		broadcastUserPresence(userID, true)
+	}
+}
+
+// untrackConnection drops the connection's presence and, if it was the
+// user's last connection on any replica, announces them as offline.
+func untrackConnection(client *hubClient, userID int) {
+	if userID == 0 {
+		return
+	}
+
+	presenceMu.Lock()
+	entry := presence[client]
+	delete(presence, client)
+
+	last := false
+	if conns, ok := userConnections[userID]; ok && conns[client] {
+		delete(conns, client)
+		if len(conns) == 0 {
+			delete(userConnections, userID)
+			last = true
+		}
+	}
+	presenceMu.Unlock()
+
+	if entry != nil || last {
+		savePresence()
+	}
+	if entry != nil {
+		broadcastTaskPresence(entry.taskID)
+	}
+	if last && !onlineElsewhere(userID) {
+		broadcastUserPresence(userID, false)
+	}
+}
+
+// setPresence marks the connection as viewing or editing a task. An empty
+// state clears it. Both the old and the new task are re-announced.
+func setPresence(client *hubClient, userID int, taskID uint, state string) {
+	presenceMu.Lock()
+	previous := presence[client]
+	if state == "" {
+		delete(presence, client)
+	} else {
+		presence[client] = &presenceEntry{
+			userID:    userID,
+			taskID:    taskID,
+			state:     state,
+			expiresAt: time.Now().Add(presenceTTL),
+		}
+	}
+	presenceMu.Unlock()
+	savePresence()
+
+	if previous != nil && (previous.taskID != taskID || state == "") {
+		broadcastTaskPresence(previous.taskID)
+	}
+	if state != "" && (previous == nil || previous.taskID != taskID || previous.state != state) {
+		broadcastTaskPresence(taskID)
+	}
+}
+
+// taskPresence lists the users currently on a task across every replica. A
+// user connected from several places is reported once, as editing if any
+// connection is.
+func taskPresence(taskID uint) []PresenceUser {
+	states := make(map[uint]string)
+	// This replica's own state is read directly, since it is always current.
+	snapshots := append(otherPresence(), localPresence())
+	for _, snapshot := range snapshots {
+		for id, state := range snapshot.Tasks[taskID] {
+			if states[id] != PresenceEditing {
+				states[id] = state
+			}
+		}
+	}
+
+	users := []PresenceUser{}
+	if len(states) == 0 {
+		return users
+	}
+
+	ids := make([]uint, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+
+	var records []User
+	database.DB.Select("id", "username", "display_name").Find(&records, ids)
+	for _, user := range records {
+		users = append(users, PresenceUser{
+			UserID:      user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			State:       states[user.ID],
+		})
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+
+	return users
+}
+
+// sweepPresence expires viewing/editing markers that were not refreshed and
+// keeps this replica's snapshot in the shared store alive.
+func sweepPresence() {
+	defer hubWorkers.Done()
+
+	ticker := time.NewTicker(presenceTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			expired := make(map[uint]bool)
+
+			presenceMu.Lock()
+			for client, entry := range presence {
+				if now.After(entry.expiresAt) {
+					expired[entry.taskID] = true
+					delete(presence, client)
+				}
+			}
+			presenceMu.Unlock()
+			savePresence()
+
+			for taskID := range expired {
+				broadcastTaskPresence(taskID)
+			}
+
+		case <-hubDone:
+			return
+		}
+	}
+}
+
+func broadcastTaskPresence(taskID uint) {
+	publish(WSMessage{
+		Type: "presence_changed",
+		Payload: map[string]interface{}{
+			"task_id": taskID,
+			"users":   taskPresence(taskID),
+		},
+		topics:    []string{TaskTopic(taskID)},
+		ephemeral: true,
+	})
+}
+
+func broadcastUserPresence(userID int, online bool) {
+	publish(WSMessage{
+		Type: "user_presence",
+		Payload: map[string]interface{}{
+			"user_id": userID,
+			"online":  online,
+		},
+		topics:    []string{TopicPresence},
+		ephemeral: true,
+	})
+}
+
+func GetTaskPresence(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id": uint(id),
+		"users":   taskPresence(uint(id)),
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const otherReplica = "other-replica"
+
+// saveOtherReplica stores the presence snapshot of a second replica, as if
+// it shared the store with this one.
+func saveOtherReplica(t *testing.T, snapshot presenceSnapshot) {
+	t.Helper()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testPresence.Save(context.Background(), otherReplica, data, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { testPresence.Remove(context.Background(), otherReplica) })
+}
+
+// expectUserPresence reads user_presence messages until one about userID
+// arrives and returns whether it reports the user online.
+func expectUserPresence(t *testing.T, conn *websocket.Conn, userID uint) bool {
+	t.Helper()
+	for {
+		event := expectEvent(t, conn, "user_presence")
+		var payload struct {
+			UserID uint `json:"user_id"`
+			Online bool `json:"online"`
+		}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.UserID == userID {
+			return payload.Online
+		}
+	}
+}
+
+func TestTaskPresenceMergesReplicas(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AdminToken, map[string]interface{}{"title": "Shared"})
+	saveOtherReplica(t, presenceSnapshot{
+		Users: []uint{f.Bob.ID},
+		Tasks: map[uint]map[uint]string{task.ID: {f.Bob.ID: PresenceEditing}},
+	})
+
+	conn := dialWebSocket(t, f.AliceToken, TaskTopic(task.ID))
+	send(t, conn, map[string]interface{}{"type": PresenceViewing, "task_id": task.ID})
+
+	event := expectEvent(t, conn, "presence_changed")
+	var payload struct {
+		Users []PresenceUser `json:"users"`
+	}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := []PresenceUser{
+		{UserID: f.Alice.ID, Username: "alice", DisplayName: "Alice", State: PresenceViewing},
+		{UserID: f.Bob.ID, Username: "bob", DisplayName: "Bob", State: PresenceEditing},
+	}
+	if fmt.Sprint(payload.Users) != fmt.Sprint(want) {
+		t.Errorf("presence_changed users = %+v, want %+v", payload.Users, want)
+	}
+
+	w := request(t, http.MethodGet, fmt.Sprintf("/api/v1/tasks/%d/presence", task.ID), f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[struct{ Users []PresenceUser }](t, w).Users; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GET presence users = %+v, want %+v", got, want)
+	}
+}
+
+func TestUserPresenceAcrossReplicas(t *testing.T) {
+	f := setup(t)
+	watcher := dialWebSocket(t, f.AliceToken, TopicPresence)
+
+	// Bob is connected to the other replica, so connecting and
+	// disconnecting here announces nothing.
+	saveOtherReplica(t, presenceSnapshot{Users: []uint{f.Bob.ID}})
+	bob := dialWebSocket(t, f.BobToken)
+	bob.Close()
+	eventually(t, "Bob's connection to close", func() bool {
+		presenceMu.Lock()
+		defer presenceMu.Unlock()
+		return len(userConnections[int(f.Bob.ID)]) == 0
+	})
+
+	// Once the other replica no longer has him, the next connection and
+	// disconnection are announced, and they are the first about Bob.
+	testPresence.Remove(context.Background(), otherReplica)
+	bob = dialWebSocket(t, f.BobToken)
+	if !expectUserPresence(t, watcher, f.Bob.ID) {
+		t.Fatal("first user_presence for Bob is offline, want online")
+	}
+	bob.Close()
+	if expectUserPresence(t, watcher, f.Bob.ID) {
+		t.Fatal("user_presence after Bob disconnected is online, want offline")
+	}
+}
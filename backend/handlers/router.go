@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"ziggler_backend/metrics"
+	"ziggler_backend/middleware"
+	"ziggler_backend/repository"
+	"ziggler_backend/service"
+	"ziggler_backend/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouter returns the engine serving every endpoint, with the handlers
+// that need them built on users and tasks.
+func NewRouter(users repository.UserRepository, tasks *service.TaskService) *gin.Engine {
+	authHandler := NewAuthHandler(users)
+	userHandler := NewUserHandler(users)
+	taskHandler := NewTaskHandler(tasks)
+
+	r := gin.New()
+	r.Use(middleware.RequestID(), tracing.Middleware(), middleware.RequestLogger(), middleware.Recovery(), metrics.Middleware())
+
+	r.GET("/metrics", metrics.Handler())
+	r.GET("/healthz", Liveness)
+	r.GET("/readyz", Readiness)
+
+	r.GET("/api/v1/ws", WebSocketHandler)
+
+	r.Use(middleware.CorsMiddleware())
+
+	api := r.Group("/api/v1")
+
+	api.GET("/health", HealthCheck)
+
+	api.GET("/events", middleware.StreamAuthMiddleware(), EventStream)
+
+	api.POST("/auth/register", authHandler.Register)
+	api.POST("/auth/login", authHandler.Login)
+
+	api.GET("/unsubscribe", ConfirmUnsubscribe)
+	api.POST("/unsubscribe", Unsubscribe)
+
+	protected := api.Group("/")
+	protected.Use(middleware.JWTAuthMiddleware())
+	{
+
+		protected.GET("/profile", authHandler.GetProfile)
+
+		protected.GET("/tasks", taskHandler.GetTasks)
+		protected.POST("/tasks", taskHandler.CreateTask)
+		protected.GET("/tasks/:id", taskHandler.GetTask)
+		protected.PUT("/tasks/:id", taskHandler.UpdateTask)
+		protected.DELETE("/tasks/:id", taskHandler.DeleteTask)
+		protected.GET("/tasks/:id/subtasks", taskHandler.GetSubtasks)
+		protected.GET("/tasks/:id/presence", GetTaskPresence)
+		protected.GET("/tasks/:id/watchers", GetTaskWatchers)
+		protected.POST("/tasks/:id/watch", WatchTask)
+		protected.DELETE("/tasks/:id/watch", UnwatchTask)
+
+		protected.GET("/users/me/notification-preferences", GetNotificationPreferences)
+		protected.PUT("/users/me/notification-preferences", UpdateNotificationPreferences)
+		protected.GET("/users/me/mentions", GetMyMentions)
+
+		protected.GET("/users", userHandler.GetUsers)
+		protected.POST("/users", userHandler.CreateUser)
+		protected.GET("/users/:id", userHandler.GetUser)
+		protected.PUT("/users/:id", userHandler.UpdateUser)
+		protected.DELETE("/users/:id", userHandler.DeleteUser)
+
+		protected.GET("/stats", GetStats)
+		protected.GET("/stats/timeseries", GetStatsTimeseries)
+		protected.GET("/stats/cycle-time", GetCycleTimeStats)
+		protected.GET("/stats/workload", GetWorkload)
+
+		protected.GET("/notifications", GetNotifications)
+		protected.GET("/notifications/unread-count", GetUnreadNotificationCount)
+		protected.PUT("/notifications/read-all", MarkAllNotificationsRead)
+		protected.PUT("/notifications/:id/read", MarkNotificationRead)
+
+	}
+
+	return r
+}
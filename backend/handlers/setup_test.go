@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"ziggler_backend/auth"
+	"ziggler_backend/config"
+	"ziggler_backend/database"
+	"ziggler_backend/pubsub"
+	"ziggler_backend/repository"
+	"ziggler_backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testRouter serves every endpoint against a single in-memory SQLite
+// database shared by the tests in this package.
+var testRouter *gin.Engine
+
+// testPresence is the shared presence store, where tests can save the
+// snapshot of another replica.
+var testPresence = pubsub.NewMemoryPresence()
+
+const testPassword = "password123"
+
+// TestMain runs the tests against an in-memory SQLite database with the
+// hub, outbox dispatcher and email sender running, as the server does. The pool holds a
+// single connection because each SQLite connection to :memory: opens a
+// separate database.
+func TestMain(m *testing.M) {
+	env := map[string]string{
+		"JWT_SECRET":           "test-secret-0123456789abcdef01234567",
+		"DB_DRIVER":            "sqlite",
+		"DATABASE_URL":         ":memory:",
+		"DB_MAX_OPEN_CONNS":    "1",
+		"DB_MAX_IDLE_CONNS":    "1",
+		"DB_CONN_MAX_LIFETIME": "0",
+		"PUBSUB_DRIVER":        "memory",
+		"OTEL_TRACES_EXPORTER": "none",
+		"LOG_LEVEL":            "error",
+	}
+	for key, value := range env {
+		os.Setenv(key, value)
+	}
+	config.LoadConfig()
+	gin.SetMode(gin.TestMode)
+
+	database.Connect()
+	if _, err := database.MigrateUp(database.DB); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate test database:", err)
+		os.Exit(1)
+	}
+	if err := InitWebSocket(pubsub.NewMemory(), testPresence); err != nil {
+		fmt.Fprintln(os.Stderr, "start hub:", err)
+		os.Exit(1)
+	}
+	StartOutboxDispatcher()
+	StartEmailNotifications()
+
+	users := repository.NewUserRepository(database.DB)
+	tasks := service.NewTaskService(repository.NewTaskRepository(database.DB, NewTaskHooks()), users)
+	testRouter = NewRouter(users, tasks)
+
+	code := m.Run()
+
+	// Let the dispatcher deliver the last events before the hub stops.
+	deadline := time.Now().Add(5 * time.Second)
+	for !outboxDrained() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	StopEmailNotifications()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ShutdownWebSocket(ctx)
+	cancel()
+	database.Close()
+	os.Exit(code)
+}
+
+// fixtures are the users every test starts with: an admin and two regular
+// users, with tokens to call the API as each of them.
+type fixtures struct {
+	Admin, Alice, Bob                User
+	AdminToken, AliceToken, BobToken string
+}
+
+// setup empties the database and creates the fixture users.
+func setup(t *testing.T) fixtures {
+	t.Helper()
+	resetDatabase(t)
+
+	hashed, err := auth.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(username, displayName, role string) (User, string) {
+		user := User{
+			Username:    username,
+			Email:       username + "@example.com",
+			Password:    hashed,
+			Role:        role,
+			DisplayName: displayName,
+		}
+		if err := database.DB.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		token, err := auth.GenerateToken(int(user.ID), user.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user, token
+	}
+
+	var f fixtures
+	f.Admin, f.AdminToken = create("admin", "Admin", database.RoleAdmin)
+	f.Alice, f.AliceToken = create("alice", "Alice", database.RoleUser)
+	f.Bob, f.BobToken = create("bob", "Bob", database.RoleUser)
+	return f
+}
+
+// resetDatabase waits for the outbox and queued emails to drain, so no
+// event or email from an earlier test is delivered during this one, then
+// deletes every row.
+func resetDatabase(t *testing.T) {
+	t.Helper()
+	eventually(t, "outbox to drain", outboxDrained)
+	eventually(t, "queued emails to be sent", emailsDrained)
+
+	tables := []string{
+		"mentions", "notifications", "notification_preferences", "task_status_changes",
+		"task_watchers", "outbox", "email_outbox", "tasks", "users",
+	}
+	for _, table := range tables {
+		if err := database.DB.Exec("DELETE FROM " + table).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	invalidateStats()
+}
+
+func outboxDrained() bool {
+	var pending int64
+	database.DB.Model(&database.OutboxEvent{}).Where("delivered_at IS NULL AND failed_at IS NULL").Count(&pending)
+	return pending == 0
+}
+
+func emailsDrained() bool {
+	var pending int64
+	database.DB.Model(&database.OutboxEmail{}).Where("sent_at IS NULL AND failed_at IS NULL").Count(&pending)
+	return pending == 0
+}
+
+// eventually polls condition until it holds, failing the test after 5 seconds.
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// request calls the router and returns the recorded response. body is
+// encoded as JSON unless it is nil; token may be empty.
+func request(t *testing.T, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+	return w
+}
+
+// expectStatus fails the test unless the response has the given status.
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, status, w.Body.String())
+	}
+}
+
+// decode unmarshals the response body into a T.
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+// errorMessage returns the "error" field of a JSON error response.
+func errorMessage(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	return decode[map[string]string](t, w)["error"]
+}
+
+// createTask creates a task as the user with token, failing the test
+// unless it succeeds.
+func createTask(t *testing.T, token string, body map[string]interface{}) Task {
+	t.Helper()
+	w := request(t, http.MethodPost, "/api/v1/tasks", token, body)
+	expectStatus(t, w, http.StatusCreated)
+	return decode[Task](t, w)
+}
+
+// updateTask updates a task as the user with token, failing the test
+// unless it succeeds.
+func updateTask(t *testing.T, token string, id uint, body map[string]interface{}) Task {
+	t.Helper()
+	w := request(t, http.MethodPut, fmt.Sprintf("/api/v1/tasks/%d", id), token, body)
+	expectStatus(t, w, http.StatusOK)
+	return decode[Task](t, w)
+}
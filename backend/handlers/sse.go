@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// EventStream serves the hub's events as Server-Sent Events for clients
+// that cannot use WebSocket. Topics are chosen with the comma separated
+// topics query parameter (default "tasks") and a reconnecting EventSource
+// resumes through the Last-Event-ID header.
+func EventStream(c *gin.Context) {
+	select {
+	case <-hubDone:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+		return
+	default:
+	}
+
+	topics := []string{TopicTasks}
+	if raw := c.Query("topics"); raw != "" {
+		topics = strings.Split(raw, ",")
+	}
+	for _, topic := range topics {
+		if !validTopic(topic) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic: " + topic})
+			return
+		}
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	userID, _ := c.Get("user_id")
+	client := newHubClient(c.Request.Context(), nil, c.Request.RemoteAddr)
+	client.userID = userID.(int)
+	if resumeFrom, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+		client.resumeFrom = resumeFrom
+		client.resumePending = true
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	clientsMu.Lock()
+	clients[client] = true
+	clientsMu.Unlock()
+
+	trackConnection(client, client.userID)
+
+	writers.Add(1)
+	defer func() {
+		clientsMu.Lock()
+		removeClient(client)
+		clientsMu.Unlock()
+		untrackConnection(client, client.userID)
+		client.close(websocket.CloseNormalClosure, "")
+		writers.Done()
+		slog.InfoContext(client.ctx, "SSE client disconnected", "remote_addr", client.remoteAddr)
+	}()
+
+	slog.InfoContext(client.ctx, "SSE client connected", "remote_addr", client.remoteAddr)
+
+	client.enqueue(WSMessage{
+		Type: "connected",
+		Payload: map[string]interface{}{
+			"message":    "Connected to event stream",
+			"latest_seq": latestSeq(),
+			"topics":     topics,
+		},
+	})
+
+	// Live events are held back during a replay so they cannot interleave
+	// with missed ones.
+	if client.resumePending {
+		holdLiveEvents(client)
+	}
+	subscribe(client, UserTopic(uint(client.userID)))
+	for _, topic := range topics {
+		subscribe(client, topic)
+	}
+	if client.resumePending {
+		client.resumePending = false
+		replayMissed(client)
+	}
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-client.send:
+			if err := writeSSE(c, msg); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+
+		case <-client.done:
+			return
+
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+func writeSSE(c *gin.Context, msg WSMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var frame strings.Builder
+	if msg.Seq > 0 {
+		fmt.Fprintf(&frame, "id: %d\n", msg.Seq)
+	}
+	fmt.Fprintf(&frame, "event: %s\ndata: %s\n\n", msg.Type, data)
+
+	if _, err := c.Writer.WriteString(frame.String()); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
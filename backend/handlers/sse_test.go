@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sseFrame struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// openEventStream opens /api/v1/events through a real HTTP server with
+// query appended to the URL and lastEventID, if set, in the Last-Event-ID
+// header. Frames are read in the background until the test ends.
+func openEventStream(t *testing.T, query, lastEventID string) (*http.Response, <-chan sseFrame) {
+	t.Helper()
+	server := httptest.NewServer(testRouter)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/events"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	frames := make(chan sseFrame, 64)
+	go func() {
+		defer close(frames)
+		var frame sseFrame
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if frame.Event != "" {
+					frames <- frame
+				}
+				frame = sseFrame{}
+			case strings.HasPrefix(line, "id: "):
+				frame.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				frame.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				frame.Data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return resp, frames
+}
+
+// expectFrame reads frames until one of the given event type arrives,
+// failing the test after 5 seconds.
+func expectFrame(t *testing.T, frames <-chan sseFrame, event string) sseFrame {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				t.Fatalf("stream ended waiting for %s", event)
+			}
+			if frame.Event == event {
+				return frame
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", event)
+		}
+	}
+}
+
+// frameTask decodes the task carried by a task event frame.
+func frameTask(t *testing.T, frame sseFrame) Task {
+	t.Helper()
+	var msg struct {
+		Payload Task `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(frame.Data), &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg.Payload
+}
+
+func TestEventStream(t *testing.T) {
+	f := setup(t)
+	resp, frames := openEventStream(t, "?access_token="+f.AliceToken, "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	expectFrame(t, frames, "connected")
+
+	// The stream is also subscribed to Alice's own topic, and the
+	// notification is queued before the task event.
+	task := createTask(t, f.AdminToken, map[string]interface{}{"title": "Streamed", "assignee_id": f.Alice.ID})
+	expectFrame(t, frames, "notification_created")
+	created := expectFrame(t, frames, "task_created")
+	if got := frameTask(t, created); got.ID != task.ID {
+		t.Errorf("task_created for task %d, want %d", got.ID, task.ID)
+	}
+	if seq, err := strconv.ParseUint(created.ID, 10, 64); err != nil || seq == 0 {
+		t.Errorf("task_created id = %q, want its sequence number", created.ID)
+	}
+}
+
+func TestEventStreamRejectsBadRequests(t *testing.T) {
+	f := setup(t)
+
+	resp, _ := openEventStream(t, "", "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without a token: status = %d, want 401", resp.StatusCode)
+	}
+
+	resp, _ = openEventStream(t, "?access_token="+f.AliceToken+"&topics="+UserTopic(f.Bob.ID), "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("another user's topic: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	f := setup(t)
+	last := latestSeq()
+
+	// Created while the client was away.
+	missed := createTask(t, f.AdminToken, map[string]interface{}{"title": "Missed"})
+	eventually(t, "the outbox to drain", outboxDrained)
+
+	_, frames := openEventStream(t, "?access_token="+f.AliceToken, strconv.FormatUint(last, 10))
+	replayed := expectFrame(t, frames, "task_created")
+	if got := frameTask(t, replayed); got.ID != missed.ID {
+		t.Errorf("replayed task_created for task %d, want %d", got.ID, missed.ID)
+	}
+	if seq, _ := strconv.ParseUint(replayed.ID, 10, 64); seq <= last {
+		t.Errorf("replayed id = %s, want after %d", replayed.ID, last)
+	}
+
+	// Live events follow the replay.
+	live := createTask(t, f.AdminToken, map[string]interface{}{"title": "Live"})
+	if got := frameTask(t, expectFrame(t, frames, "task_created")); got.ID != live.ID {
+		t.Errorf("task_created after the replay for task %d, want %d", got.ID, live.ID)
+	}
+}
+
+func TestEventStreamResyncRequired(t *testing.T) {
+	f := setup(t)
+
+	// The client claims to have seen events the log never had.
+	_, frames := openEventStream(t, "?access_token="+f.AliceToken+fmt.Sprintf("&last_event_id=%d", latestSeq()+100), "")
+	resync := expectFrame(t, frames, "resync_required")
+
+	var msg struct {
+		Payload struct {
+			LatestSeq uint64 `json:"latest_seq"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(resync.Data), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Payload.LatestSeq != latestSeq() {
+		t.Errorf("latest_seq = %d, want %d", msg.Payload.LatestSeq, latestSeq())
+	}
+}
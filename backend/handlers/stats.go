@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"ziggler_backend/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TaskStatusChange = database.TaskStatusChange
+
+const (
+	statsDateLayout = "2006-01-02"
+
+	// Timeseries requests are limited to this many points.
+	maxTimeseriesPoints = 366
+
+	// GetStats responses are reused for this long unless a task or user
+	// change invalidates them first.
+	statsCacheTTL = 10 * time.Second
+)
+
+type statsCacheEntry struct {
+	response  StatsResponse
+	expiresAt time.Time
+}
+
+// statsCache holds GetStats responses by target user ID, 0 for all users.
+var (
+	statsCacheMu sync.Mutex
+	statsCache   = make(map[uint]statsCacheEntry)
+)
+
+var taskStatuses = []string{
+	database.TaskStatusTodo,
+	database.TaskStatusInProgress,
+	database.TaskStatusDone,
+	database.TaskStatusCancelled,
+}
+
+type TimeseriesPoint struct {
+	Date      string           `json:"date"`
+	Created   int64            `json:"created"`
+	Completed int64            `json:"completed"`
+	Backlog   int64            `json:"backlog"`
+	Statuses  map[string]int64 `json:"statuses"`
+}
+
+type TimeseriesResponse struct {
+	Interval    string            `json:"interval"`
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	UserID      *uint             `json:"user_id,omitempty"`
+	ParentID    *uint             `json:"parent_id,omitempty"`
+	Points      []TimeseriesPoint `json:"points"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// DurationStats summarises a set of durations in hours.
+type DurationStats struct {
+	Count       int     `json:"count"`
+	MedianHours float64 `json:"median_hours"`
+	P85Hours    float64 `json:"p85_hours"`
+}
+
+type FlowTimeStats struct {
+	LeadTime  DurationStats `json:"lead_time"`
+	CycleTime DurationStats `json:"cycle_time"`
+}
+
+type UserFlowTimeStats struct {
+	UserID      uint   `json:"user_id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	FlowTimeStats
+}
+
+type CycleTimeResponse struct {
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Overall     FlowTimeStats       `json:"overall"`
+	ByAssignee  []UserFlowTimeStats `json:"by_assignee"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+func cachedStats(key uint) (StatsResponse, bool) {
+	statsCacheMu.Lock()
+	defer statsCacheMu.Unlock()
+
+	entry, ok := statsCache[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return StatsResponse{}, false
+	}
+	return entry.response, true
+}
+
+func storeStats(key uint, response StatsResponse) {
+	statsCacheMu.Lock()
+	defer statsCacheMu.Unlock()
+	statsCache[key] = statsCacheEntry{response: response, expiresAt: time.Now().Add(statsCacheTTL)}
+}
+
+// invalidateStats drops every cached GetStats response. It runs on every
+// replica as stats_changed events are delivered.
+func invalidateStats() {
+	statsCacheMu.Lock()
+	defer statsCacheMu.Unlock()
+	statsCache = make(map[uint]statsCacheEntry)
+}
+
+// userStatsChanged queues a stats_changed event after a user was created,
+// changed or deleted, since stats list users by name. The user write has
+// already committed, so if the event cannot be queued, cached stats stay
+// stale until they expire.
+func userStatsChanged(c *gin.Context, userID uint) {
+	err := enqueueEvent(dbFor(c), WSMessage{
+		Type: "stats_changed",
+		Payload: map[string]interface{}{
+			"user_id": userID,
+		},
+		topics: []string{TopicStats},
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to queue stats_changed", "user_id", userID, "error", err)
+		return
+	}
+	wakeOutbox()
+}
+
+// recordStatusChange adds an entry to the task's status history within tx.
+// from is empty when the task is being created.
+func recordStatusChange(tx *gorm.DB, actorID uint, task Task, from string) error {
+	return tx.Create(&TaskStatusChange{
+		TaskID:      task.ID,
+		FromStatus:  from,
+		ToStatus:    task.Status,
+		ChangedByID: &actorID,
+		ChangedAt:   task.UpdatedAt,
+	}).Error
+}
+
+// descendantIDs returns the IDs of every task below the given one,
+// including deleted ones.
+func descendantIDs(db *gorm.DB, parentID uint) ([]uint, error) {
+	var ids []uint
+	frontier := []uint{parentID}
+	seen := map[uint]bool{parentID: true}
+	for len(frontier) > 0 {
+		var children []uint
+		if err := db.Unscoped().Model(&Task{}).Where("parent_id IN ?", frontier).Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		frontier = frontier[:0]
+		for _, id := range children {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+				frontier = append(frontier, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// parseStatsRange reads the from/to query parameters (inclusive dates,
+// UTC). The range defaults to the last 30 days.
+func parseStatsRange(c *gin.Context) (time.Time, time.Time, bool) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -29), today
+
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.Parse(statsDateLayout, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return from, to, false
+		}
+		from = parsed
+	}
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := time.Parse(statsDateLayout, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return from, to, false
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return from, to, false
+	}
+
+	return from, to, true
+}
+
+// GetStatsTimeseries replays the status history into per-day or per-week
+// points: tasks created and completed in each period, and how many tasks
+// were in each status (and open) at its end. user_id scopes to tasks
+// currently assigned to a user, parent_id to everything below a task.
+// Deleted tasks are included until the moment they were deleted, so
+// deleting a task never changes earlier points.
+func GetStatsTimeseries(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	from, to, ok := parseStatsRange(c)
+	if !ok {
+		return
+	}
+
+	interval := c.DefaultQuery("interval", "day")
+	step := 1
+	switch interval {
+	case "day":
+	case "week":
+		step = 7
+		// Weeks start on Monday.
+		from = from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval, expected day or week"})
+		return
+	}
+
+	end := to.AddDate(0, 0, 1)
+	if int(end.Sub(from).Hours()/24)/step > maxTimeseriesPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date range too large"})
+		return
+	}
+
+	response := TimeseriesResponse{
+		Interval: interval,
+		From:     from.Format(statsDateLayout),
+		To:       to.Format(statsDateLayout),
+	}
+
+	scope := dbFor(c).Unscoped().Model(&Task{}).Select("id")
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		uid := uint(id)
+		response.UserID = &uid
+		scope = scope.Where("assignee_id = ?", uid)
+	}
+	if raw := c.Query("parent_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent task ID"})
+			return
+		}
+		pid := uint(id)
+		response.ParentID = &pid
+
+		ids, err := descendantIDs(dbFor(c), pid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subtasks"})
+			return
+		}
+		scope = scope.Where("id IN ?", append(ids, 0))
+	}
+
+	var changes []TaskStatusChange
+	if err := dbFor(c).
+		Where("task_id IN (?) AND changed_at < ?", scope, end).
+		Order("changed_at asc, id asc").
+		Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch status history"})
+		return
+	}
+
+	// A deletion is replayed as a change to no status at all.
+	var deleted []Task
+	if err := dbFor(c).Unscoped().
+		Select("id", "deleted_at").
+		Where("id IN (?) AND deleted_at IS NOT NULL AND deleted_at < ?", scope, end).
+		Find(&deleted).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deleted tasks"})
+		return
+	}
+	for _, task := range deleted {
+		changes = append(changes, TaskStatusChange{TaskID: task.ID, ChangedAt: task.DeletedAt.Time})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].ChangedAt.Before(changes[j].ChangedAt) })
+
+	current := make(map[uint]string)
+	counts := make(map[string]int64)
+	apply := func(change TaskStatusChange) {
+		if previous, ok := current[change.TaskID]; ok {
+			counts[previous]--
+		}
+		if change.ToStatus == "" {
+			delete(current, change.TaskID)
+			return
+		}
+		current[change.TaskID] = change.ToStatus
+		counts[change.ToStatus]++
+	}
+
+	i := 0
+	for i < len(changes) && changes[i].ChangedAt.Before(from) {
+		apply(changes[i])
+		i++
+	}
+
+	response.Points = []TimeseriesPoint{}
+	for start := from; start.Before(end); start = start.AddDate(0, 0, step) {
+		next := start.AddDate(0, 0, step)
+		point := TimeseriesPoint{Date: start.Format(statsDateLayout)}
+
+		for i < len(changes) && changes[i].ChangedAt.Before(next) {
+			change := changes[i]
+			if change.FromStatus == "" && change.ToStatus != "" {
+				point.Created++
+			}
+			if change.ToStatus == database.TaskStatusDone && change.FromStatus != database.TaskStatusDone {
+				point.Completed++
+			}
+			apply(change)
+			i++
+		}
+
+		point.Statuses = make(map[string]int64, len(taskStatuses))
+		for _, status := range taskStatuses {
+			point.Statuses[status] = counts[status]
+		}
+		point.Backlog = counts[database.TaskStatusTodo] + counts[database.TaskStatusInProgress]
+
+		response.Points = append(response.Points, point)
+	}
+
+	response.GeneratedAt = time.Now()
+	c.JSON(http.StatusOK, response)
+}
+
+// percentile interpolates linearly between the closest ranks of sorted.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+func summarizeDurations(hours []float64) DurationStats {
+	sort.Float64s(hours)
+	return DurationStats{
+		Count:       len(hours),
+		MedianHours: math.Round(percentile(hours, 0.5)*100) / 100,
+		P85Hours:    math.Round(percentile(hours, 0.85)*100) / 100,
+	}
+}
+
+type flowDurations struct {
+	lead  []float64
+	cycle []float64
+}
+
+func (d *flowDurations) add(task Task) {
+	d.lead = append(d.lead, task.CompletedAt.Sub(task.CreatedAt).Hours())
+	if task.StartedAt != nil && !task.StartedAt.After(*task.CompletedAt) {
+		d.cycle = append(d.cycle, task.CompletedAt.Sub(*task.StartedAt).Hours())
+	}
+}
+
+func (d *flowDurations) stats() FlowTimeStats {
+	return FlowTimeStats{
+		LeadTime:  summarizeDurations(d.lead),
+		CycleTime: summarizeDurations(d.cycle),
+	}
+}
+
+// GetCycleTimeStats reports median and 85th percentile lead time (created
+// to first done) and cycle time (first in_progress to first done) for tasks
+// completed within the date range, overall and per current assignee. Tasks
+// that never went through in_progress only count towards lead time.
+func GetCycleTimeStats(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	from, to, ok := parseStatsRange(c)
+	if !ok {
+		return
+	}
+
+	var tasks []Task
+	if err := dbFor(c).
+		Select("id", "assignee_id", "created_at", "started_at", "completed_at").
+		Where("completed_at >= ? AND completed_at < ?", from, to.AddDate(0, 0, 1)).
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch completed tasks"})
+		return
+	}
+
+	var overall flowDurations
+	byAssignee := make(map[uint]*flowDurations)
+	for _, task := range tasks {
+		overall.add(task)
+		if task.AssigneeID == nil {
+			continue
+		}
+		if byAssignee[*task.AssigneeID] == nil {
+			byAssignee[*task.AssigneeID] = &flowDurations{}
+		}
+		byAssignee[*task.AssigneeID].add(task)
+	}
+
+	response := CycleTimeResponse{
+		From:       from.Format(statsDateLayout),
+		To:         to.Format(statsDateLayout),
+		Overall:    overall.stats(),
+		ByAssignee: []UserFlowTimeStats{},
+	}
+
+	if len(byAssignee) > 0 {
+		ids := make([]uint, 0, len(byAssignee))
+		for id := range byAssignee {
+			ids = append(ids, id)
+		}
+
+		var users []User
+		if err := dbFor(c).Order("id").Find(&users, ids).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignees"})
+			return
+		}
+		for _, user := range users {
+			response.ByAssignee = append(response.ByAssignee, UserFlowTimeStats{
+				UserID:        user.ID,
+				Username:      user.Username,
+				DisplayName:   user.DisplayName,
+				FlowTimeStats: byAssignee[user.ID].stats(),
+			})
+		}
+	}
+
+	response.GeneratedAt = time.Now()
+	c.JSON(http.StatusOK, response)
+}
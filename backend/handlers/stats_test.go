@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"ziggler_backend/database"
+)
+
+// seedStatsTasks creates tasks with known counts:
+//
+//	Alice: todo, in_progress, done, done
+//	Bob:   done, cancelled (and one deleted todo)
+//	nobody: todo
+func seedStatsTasks(t *testing.T, f fixtures) {
+	t.Helper()
+	for _, task := range []struct {
+		assignee *uint
+		status   string
+	}{
+		{&f.Alice.ID, "todo"},
+		{&f.Alice.ID, "in_progress"},
+		{&f.Alice.ID, "done"},
+		{&f.Alice.ID, "done"},
+		{&f.Bob.ID, "done"},
+		{&f.Bob.ID, "cancelled"},
+		{nil, "todo"},
+	} {
+		body := map[string]interface{}{"title": "Task", "status": task.status}
+		if task.assignee != nil {
+			body["assignee_id"] = *task.assignee
+		}
+		createTask(t, f.AdminToken, body)
+	}
+
+	deleted := createTask(t, f.AdminToken, map[string]interface{}{"title": "Gone", "assignee_id": f.Bob.ID})
+	w := request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", deleted.ID), f.AdminToken, nil)
+	expectStatus(t, w, http.StatusNoContent)
+}
+
+func TestGetStats(t *testing.T) {
+	f := setup(t)
+	seedStatsTasks(t, f)
+
+	w := request(t, http.MethodGet, "/api/v1/stats", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	stats := decode[StatsResponse](t, w)
+
+	wantOverall := TaskStats{
+		TotalTasks:      7,
+		TodoTasks:       2,
+		InProgressTasks: 1,
+		CompletedTasks:  3,
+		CancelledTasks:  1,
+		UnassignedTasks: 1,
+	}
+	if stats.OverallStats != wantOverall {
+		t.Errorf("overall = %+v, want %+v", stats.OverallStats, wantOverall)
+	}
+
+	wantUsers := []UserStats{
+		{UserID: f.Alice.ID, Username: "alice", DisplayName: "Alice", TodoTasks: 1, InProgress: 1, CompletedTasks: 2, TotalTasks: 4, CompletionRate: 50},
+		{UserID: f.Bob.ID, Username: "bob", DisplayName: "Bob", CompletedTasks: 1, CancelledTasks: 1, TotalTasks: 2, CompletionRate: 50},
+	}
+	if fmt.Sprint(stats.UserStats) != fmt.Sprint(wantUsers) {
+		t.Errorf("user stats = %+v, want %+v", stats.UserStats, wantUsers)
+	}
+}
+
+func TestGetStatsForUser(t *testing.T) {
+	f := setup(t)
+	seedStatsTasks(t, f)
+
+	w := request(t, http.MethodGet, fmt.Sprintf("/api/v1/stats?user_id=%d", f.Bob.ID), f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	stats := decode[StatsResponse](t, w)
+	if len(stats.UserStats) != 1 || stats.UserStats[0].UserID != f.Bob.ID || stats.UserStats[0].TotalTasks != 2 {
+		t.Errorf("user stats = %+v, want only Bob with 2 tasks", stats.UserStats)
+	}
+
+	// A user without tasks is listed with zero counts.
+	w = request(t, http.MethodGet, fmt.Sprintf("/api/v1/stats?user_id=%d", f.Admin.ID), f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	stats = decode[StatsResponse](t, w)
+	if len(stats.UserStats) != 1 || stats.UserStats[0].TotalTasks != 0 || stats.UserStats[0].CompletionRate != 0 {
+		t.Errorf("user stats = %+v, want Admin with no tasks", stats.UserStats)
+	}
+
+	w = request(t, http.MethodGet, "/api/v1/stats?user_id=9999", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = request(t, http.MethodGet, "/api/v1/stats?user_id=bob", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestGetStatsRefreshesAfterTaskChange(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Task", "assignee_id": f.Alice.ID})
+
+	w := request(t, http.MethodGet, "/api/v1/stats", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if stats := decode[StatsResponse](t, w); stats.OverallStats.CompletedTasks != 0 {
+		t.Fatalf("completed = %d before completing the task", stats.OverallStats.CompletedTasks)
+	}
+
+	updateTask(t, f.AliceToken, task.ID, map[string]interface{}{"status": "done"})
+
+	// The cached response is dropped once the task event is delivered.
+	eventually(t, "stats to show the completed task", func() bool {
+		w := request(t, http.MethodGet, "/api/v1/stats", f.AliceToken, nil)
+		return w.Code == http.StatusOK && decode[StatsResponse](t, w).OverallStats.CompletedTasks == 1
+	})
+}
+
+func TestGetStatsCachesByUserID(t *testing.T) {
+	f := setup(t)
+
+	for _, query := range []string{fmt.Sprint(f.Bob.ID), fmt.Sprintf("0%d", f.Bob.ID)} {
+		w := request(t, http.MethodGet, "/api/v1/stats?user_id="+query, f.AliceToken, nil)
+		expectStatus(t, w, http.StatusOK)
+	}
+	statsCacheMu.Lock()
+	entries := len(statsCache)
+	statsCacheMu.Unlock()
+	if entries != 1 {
+		t.Errorf("%d cache entries for the same user, want 1", entries)
+	}
+}
+
+func TestGetStatsRefreshesAfterUserChange(t *testing.T) {
+	f := setup(t)
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Task", "assignee_id": f.Bob.ID})
+	eventually(t, "the outbox to drain", outboxDrained)
+
+	w := request(t, http.MethodGet, "/api/v1/stats", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if stats := decode[StatsResponse](t, w); len(stats.UserStats) != 1 {
+		t.Fatalf("user stats = %+v, want Bob", stats.UserStats)
+	}
+
+	expectStatus(t, request(t, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", f.Bob.ID), f.AdminToken, nil), http.StatusNoContent)
+
+	eventually(t, "stats to drop the deleted user", func() bool {
+		w := request(t, http.MethodGet, "/api/v1/stats", f.AliceToken, nil)
+		return w.Code == http.StatusOK && len(decode[StatsResponse](t, w).UserStats) == 0
+	})
+}
+
+func TestGetWorkload(t *testing.T) {
+	f := setup(t)
+	seedStatsTasks(t, f)
+
+	w := request(t, http.MethodGet, "/api/v1/stats/workload?threshold=1", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	workload := decode[WorkloadResponse](t, w)
+
+	open := make(map[uint]UserWorkload)
+	for _, user := range workload.Users {
+		open[user.UserID] = user
+	}
+	if got := open[f.Alice.ID]; got.OpenTasks != 2 || got.TodoTasks != 1 || got.InProgressTasks != 1 || !got.Overloaded {
+		t.Errorf("Alice = %+v, want 2 open tasks and overloaded", got)
+	}
+	if got := open[f.Bob.ID]; got.OpenTasks != 0 || got.Overloaded {
+		t.Errorf("Bob = %+v, want no open tasks", got)
+	}
+	if len(workload.Suggestions) == 0 || workload.Suggestions[0].FromUserID != f.Alice.ID {
+		t.Errorf("suggestions = %+v, want a task moved away from Alice", workload.Suggestions)
+	}
+
+	w = request(t, http.MethodGet, "/api/v1/stats/workload?threshold=0", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestStatsTimeseriesKeepsDeletedTasks(t *testing.T) {
+	f := setup(t)
+	kept := createTask(t, f.AdminToken, map[string]interface{}{"title": "Kept"})
+	gone := createTask(t, f.AdminToken, map[string]interface{}{"title": "Gone"})
+
+	// Both tasks were created yesterday.
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+	database.DB.Model(&TaskStatusChange{}).Where("task_id IN ?", []uint{kept.ID, gone.ID}).
+		Update("changed_at", yesterday.Add(time.Hour))
+
+	timeseries := func() []TimeseriesPoint {
+		path := fmt.Sprintf("/api/v1/stats/timeseries?from=%s&to=%s",
+			yesterday.Format(statsDateLayout), today.Format(statsDateLayout))
+		w := request(t, http.MethodGet, path, f.AliceToken, nil)
+		expectStatus(t, w, http.StatusOK)
+		return decode[TimeseriesResponse](t, w).Points
+	}
+	before := timeseries()
+
+	w := request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", gone.ID), f.AdminToken, nil)
+	expectStatus(t, w, http.StatusNoContent)
+	after := timeseries()
+
+	if len(after) != 2 {
+		t.Fatalf("got %d points, want 2", len(after))
+	}
+	if fmt.Sprint(after[0]) != fmt.Sprint(before[0]) {
+		t.Errorf("yesterday = %+v after deleting, want unchanged %+v", after[0], before[0])
+	}
+	if after[0].Created != 2 || after[0].Backlog != 2 {
+		t.Errorf("yesterday = %+v, want 2 created and a backlog of 2", after[0])
+	}
+	if after[1].Backlog != 1 || after[1].Statuses["todo"] != 1 {
+		t.Errorf("today = %+v, want a backlog of 1 after the deletion", after[1])
+	}
+}
+
+func TestGetCycleTimeStats(t *testing.T) {
+	f := setup(t)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	created := today.Add(time.Hour)
+
+	// finish creates a task for assignee and rewrites its flow timestamps
+	// to hours after created; started is nil for a task never in progress.
+	finish := func(assignee uint, started *float64, completed float64) {
+		t.Helper()
+		task := createTask(t, f.AdminToken, map[string]interface{}{"title": "Flow", "assignee_id": assignee})
+		updates := map[string]interface{}{
+			"status":       "done",
+			"created_at":   created,
+			"completed_at": created.Add(time.Duration(completed * float64(time.Hour))),
+			"started_at":   nil,
+		}
+		if started != nil {
+			updates["started_at"] = created.Add(time.Duration(*started * float64(time.Hour)))
+		}
+		if err := database.DB.Model(&Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	two := 2.0
+	finish(f.Alice.ID, &two, 10) // lead 10h, cycle 8h
+	finish(f.Bob.ID, nil, 4)     // lead 4h, never in progress
+	finish(f.Bob.ID, &two, 48)   // completed two days later, out of range
+
+	path := "/api/v1/stats/cycle-time?from=" + today.Format(statsDateLayout) + "&to=" + today.Format(statsDateLayout)
+	w := request(t, http.MethodGet, path, f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	stats := decode[CycleTimeResponse](t, w)
+
+	wantOverall := FlowTimeStats{
+		LeadTime:  DurationStats{Count: 2, MedianHours: 7, P85Hours: 9.1},
+		CycleTime: DurationStats{Count: 1, MedianHours: 8, P85Hours: 8},
+	}
+	if stats.Overall != wantOverall {
+		t.Errorf("overall = %+v, want %+v", stats.Overall, wantOverall)
+	}
+
+	wantUsers := []UserFlowTimeStats{
+		{UserID: f.Alice.ID, Username: "alice", DisplayName: "Alice", FlowTimeStats: FlowTimeStats{
+			LeadTime:  DurationStats{Count: 1, MedianHours: 10, P85Hours: 10},
+			CycleTime: DurationStats{Count: 1, MedianHours: 8, P85Hours: 8},
+		}},
+		{UserID: f.Bob.ID, Username: "bob", DisplayName: "Bob", FlowTimeStats: FlowTimeStats{
+			LeadTime: DurationStats{Count: 1, MedianHours: 4, P85Hours: 4},
+		}},
+	}
+	if fmt.Sprint(stats.ByAssignee) != fmt.Sprint(wantUsers) {
+		t.Errorf("by assignee = %+v, want %+v", stats.ByAssignee, wantUsers)
+	}
+
+	w = request(t, http.MethodGet, "/api/v1/stats/cycle-time?from=2024-02-01&to=2024-01-01", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
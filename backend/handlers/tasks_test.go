@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"ziggler_backend/database"
+)
+
+func TestCreateTask(t *testing.T) {
+	f := setup(t)
+
+	task := createTask(t, f.AliceToken, map[string]interface{}{
+		"title":       "Write tests",
+		"description": "Cover the handlers",
+		"assignee_id": f.Bob.ID,
+	})
+	if task.CreatorID != f.Alice.ID {
+		t.Errorf("creator_id = %d, want %d", task.CreatorID, f.Alice.ID)
+	}
+	if task.Status != database.TaskStatusTodo {
+		t.Errorf("status = %q, want todo", task.Status)
+	}
+	if task.Assignee == nil || task.Assignee.ID != f.Bob.ID {
+		t.Errorf("assignee = %+v, want Bob", task.Assignee)
+	}
+	if len(task.Watchers) != 2 {
+		t.Errorf("got %d watchers, want the creator and the assignee", len(task.Watchers))
+	}
+
+	var history []database.TaskStatusChange
+	database.DB.Where("task_id = ?", task.ID).Find(&history)
+	if len(history) != 1 || history[0].FromStatus != "" || history[0].ToStatus != database.TaskStatusTodo {
+		t.Errorf("status history = %+v, want one entry into todo", history)
+	}
+
+	w := request(t, http.MethodGet, fmt.Sprintf("/api/v1/tasks/%d", task.ID), f.BobToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[Task](t, w); got.Title != "Write tests" || got.Description != "Cover the handlers" {
+		t.Errorf("GET returned %q / %q", got.Title, got.Description)
+	}
+}
+
+func TestCreateTaskStartedInProgress(t *testing.T) {
+	f := setup(t)
+
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Already going", "status": "in_progress"})
+	if task.StartedAt == nil {
+		t.Error("started_at not set for a task created in progress")
+	}
+	if task.CompletedAt != nil {
+		t.Error("completed_at set for a task that is not done")
+	}
+}
+
+func TestCreateTaskValidation(t *testing.T) {
+	f := setup(t)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want string
+	}{
+		{"missing title", map[string]interface{}{"description": "No title"}, "Title is required"},
+		{"blank title", map[string]interface{}{"title": "  "}, "Title is required"},
+		{"unknown status", map[string]interface{}{"title": "Task", "status": "blocked"}, "Invalid status"},
+		{"unknown assignee", map[string]interface{}{"title": "Task", "assignee_id": 9999}, "Assignee not found"},
+		{"unknown parent", map[string]interface{}{"title": "Task", "parent_id": 9999}, "Parent task not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(t, http.MethodPost, "/api/v1/tasks", f.AliceToken, tt.body)
+			expectStatus(t, w, http.StatusBadRequest)
+			if msg := errorMessage(t, w); msg != tt.want {
+				t.Errorf("error = %q, want %q", msg, tt.want)
+			}
+		})
+	}
+
+	w := request(t, http.MethodPost, "/api/v1/tasks", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestUpdateTask(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Draft", "assignee_id": f.Bob.ID})
+
+	updated := updateTask(t, f.BobToken, task.ID, map[string]interface{}{"title": "Final", "status": "in_progress"})
+	if updated.Title != "Final" || updated.Status != database.TaskStatusInProgress {
+		t.Errorf("got %q / %q, want Final / in_progress", updated.Title, updated.Status)
+	}
+	if updated.StartedAt == nil {
+		t.Fatal("started_at not set when the task moved to in_progress")
+	}
+	if updated.CreatorID != f.Alice.ID {
+		t.Errorf("creator changed to %d", updated.CreatorID)
+	}
+
+	done := updateTask(t, f.BobToken, task.ID, map[string]interface{}{"status": "done"})
+	if done.CompletedAt == nil {
+		t.Error("completed_at not set when the task moved to done")
+	}
+	if !done.StartedAt.Equal(*updated.StartedAt) {
+		t.Errorf("started_at moved from %v to %v", updated.StartedAt, done.StartedAt)
+	}
+
+	due := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	withDue := updateTask(t, f.AliceToken, task.ID, map[string]interface{}{"due_date": due, "unassigned": true})
+	if withDue.AssigneeID != nil {
+		t.Errorf("assignee_id = %d, want none", *withDue.AssigneeID)
+	}
+	if withDue.DueDate == nil || !withDue.DueDate.Equal(due) {
+		t.Errorf("due_date = %v, want %v", withDue.DueDate, due)
+	}
+
+	cleared := updateTask(t, f.AliceToken, task.ID, map[string]interface{}{"clear_due_date": true})
+	if cleared.DueDate != nil {
+		t.Errorf("due_date = %v, want none", cleared.DueDate)
+	}
+
+	var history []database.TaskStatusChange
+	database.DB.Where("task_id = ?", task.ID).Order("id").Find(&history)
+	if len(history) != 3 {
+		t.Fatalf("got %d status changes, want 3", len(history))
+	}
+	if history[2].FromStatus != database.TaskStatusInProgress || history[2].ToStatus != database.TaskStatusDone {
+		t.Errorf("last change = %s -> %s, want in_progress -> done", history[2].FromStatus, history[2].ToStatus)
+	}
+}
+
+func TestUpdateTaskErrors(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Task"})
+	path := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+	w := request(t, http.MethodPut, "/api/v1/tasks/9999", f.AliceToken, map[string]interface{}{"title": "x"})
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = request(t, http.MethodPut, "/api/v1/tasks/abc", f.AliceToken, map[string]interface{}{"title": "x"})
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = request(t, http.MethodPut, path, f.AliceToken, map[string]interface{}{"assignee_id": 9999})
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestDeleteTask(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Short lived"})
+	path := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+	w := request(t, http.MethodDelete, path, f.AliceToken, nil)
+	expectStatus(t, w, http.StatusNoContent)
+
+	w = request(t, http.MethodGet, path, f.AliceToken, nil)
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = request(t, http.MethodDelete, path, f.AliceToken, nil)
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = request(t, http.MethodGet, "/api/v1/tasks", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if page := decode[PaginatedResponse](t, w); page.Total != 0 {
+		t.Errorf("total = %d after deleting the only task", page.Total)
+	}
+
+	// Deletion is soft: the row is kept.
+	var count int64
+	database.DB.Unscoped().Model(&Task{}).Where("id = ?", task.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("task row count = %d, want 1", count)
+	}
+}
+
+func TestGetTasksPagination(t *testing.T) {
+	f := setup(t)
+	for i := 1; i <= 7; i++ {
+		createTask(t, f.AliceToken, map[string]interface{}{"title": fmt.Sprintf("Task %d", i)})
+	}
+
+	tests := []struct {
+		query            string
+		page, size, rows int
+		totalPages       int
+	}{
+		{"page=1&page_size=3", 1, 3, 3, 3},
+		{"page=3&page_size=3", 3, 3, 1, 3},
+		{"page=4&page_size=3", 4, 3, 0, 3},
+		{"page=0&page_size=500", 1, 50, 7, 1},
+		{"page=x&page_size=-1", 1, 50, 7, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := request(t, http.MethodGet, "/api/v1/tasks?"+tt.query, f.AliceToken, nil)
+			expectStatus(t, w, http.StatusOK)
+			page := decode[PaginatedResponse](t, w)
+			if page.Total != 7 || page.Page != tt.page || page.PageSize != tt.size || page.TotalPages != tt.totalPages {
+				t.Errorf("got total %d, page %d, size %d, pages %d", page.Total, page.Page, page.PageSize, page.TotalPages)
+			}
+			if len(page.Data) != tt.rows {
+				t.Errorf("got %d rows, want %d", len(page.Data), tt.rows)
+			}
+		})
+	}
+}
+
+func TestGetTasksSorting(t *testing.T) {
+	f := setup(t)
+	early := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "banana", "due_date": late})
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "apple"})
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "cherry", "due_date": early})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sort_by=title&sort_order=asc", []string{"apple", "banana", "cherry"}},
+		{"sort_by=title&sort_order=desc", []string{"cherry", "banana", "apple"}},
+		{"sort_by=id&sort_order=asc", []string{"banana", "apple", "cherry"}},
+		// Tasks without a due date come first ascending and last descending.
+		{"sort_by=due_date&sort_order=asc", []string{"apple", "cherry", "banana"}},
+		{"sort_by=due_date&sort_order=desc", []string{"banana", "cherry", "apple"}},
+		// Unknown fields and orders fall back to newest first.
+		{"sort_by=password&sort_order=sideways", []string{"cherry", "apple", "banana"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := request(t, http.MethodGet, "/api/v1/tasks?"+tt.query, f.AliceToken, nil)
+			expectStatus(t, w, http.StatusOK)
+			page := decode[PaginatedResponse](t, w)
+			var got []string
+			for _, task := range page.Data {
+				got = append(got, task.Title)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTasksMyTasks(t *testing.T) {
+	f := setup(t)
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Mine", "assignee_id": f.Alice.ID})
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Bob's", "assignee_id": f.Bob.ID})
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Nobody's"})
+
+	w := request(t, http.MethodGet, "/api/v1/tasks?my_tasks=true", f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	page := decode[PaginatedResponse](t, w)
+	if page.Total != 1 || len(page.Data) != 1 || page.Data[0].Title != "Mine" {
+		t.Errorf("got %d tasks (%+v), want only Mine", page.Total, page.Data)
+	}
+}
+
+func TestTaskHierarchy(t *testing.T) {
+	f := setup(t)
+	root := createTask(t, f.AliceToken, map[string]interface{}{"title": "Root"})
+	child := createTask(t, f.AliceToken, map[string]interface{}{"title": "Child", "parent_id": root.ID})
+	grandchild := createTask(t, f.AliceToken, map[string]interface{}{"title": "Grandchild", "parent_id": child.ID})
+
+	w := request(t, http.MethodGet, fmt.Sprintf("/api/v1/tasks/%d/subtasks", root.ID), f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if subtasks := decode[[]Task](t, w); len(subtasks) != 1 || subtasks[0].ID != child.ID {
+		t.Errorf("subtasks of root = %+v, want only Child", subtasks)
+	}
+
+	t.Run("own parent", func(t *testing.T) {
+		w := request(t, http.MethodPut, fmt.Sprintf("/api/v1/tasks/%d", root.ID), f.AliceToken, map[string]interface{}{"parent_id": root.ID})
+		expectStatus(t, w, http.StatusBadRequest)
+		if msg := errorMessage(t, w); msg != "Task cannot be its own parent" {
+			t.Errorf("error = %q", msg)
+		}
+	})
+
+	t.Run("direct cycle", func(t *testing.T) {
+		w := request(t, http.MethodPut, fmt.Sprintf("/api/v1/tasks/%d", root.ID), f.AliceToken, map[string]interface{}{"parent_id": child.ID})
+		expectStatus(t, w, http.StatusBadRequest)
+		if msg := errorMessage(t, w); msg != "Circular dependency detected" {
+			t.Errorf("error = %q", msg)
+		}
+	})
+
+	t.Run("deep cycle", func(t *testing.T) {
+		w := request(t, http.MethodPut, fmt.Sprintf("/api/v1/tasks/%d", root.ID), f.AliceToken, map[string]interface{}{"parent_id": grandchild.ID})
+		expectStatus(t, w, http.StatusBadRequest)
+		if msg := errorMessage(t, w); msg != "Circular dependency detected" {
+			t.Errorf("error = %q", msg)
+		}
+	})
+
+	t.Run("delete with subtasks", func(t *testing.T) {
+		w := request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", child.ID), f.AliceToken, nil)
+		expectStatus(t, w, http.StatusBadRequest)
+		if msg := errorMessage(t, w); msg != "Cannot delete task with subtasks" {
+			t.Errorf("error = %q", msg)
+		}
+	})
+
+	t.Run("move", func(t *testing.T) {
+		moved := updateTask(t, f.AliceToken, grandchild.ID, map[string]interface{}{"parent_id": root.ID})
+		if moved.ParentID == nil || *moved.ParentID != root.ID {
+			t.Errorf("parent_id = %v, want %d", moved.ParentID, root.ID)
+		}
+
+		// Child has no subtasks left, so it can be deleted.
+		w := request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", child.ID), f.AliceToken, nil)
+		expectStatus(t, w, http.StatusNoContent)
+	})
+
+	t.Run("move to top level", func(t *testing.T) {
+		top := updateTask(t, f.AliceToken, grandchild.ID, map[string]interface{}{"parent_id": 0})
+		if top.ParentID != nil {
+			t.Errorf("parent_id = %d, want none", *top.ParentID)
+		}
+	})
+}
+
+func TestUpdateTaskValidation(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Task"})
+	path := fmt.Sprintf("/api/v1/tasks/%d", task.ID)
+
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		want string
+	}{
+		{"empty title", map[string]interface{}{"title": ""}, "Title is required"},
+		{"blank title", map[string]interface{}{"title": " \t"}, "Title is required"},
+		{"unknown status", map[string]interface{}{"status": "blocked"}, "Invalid status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(t, http.MethodPut, path, f.AliceToken, tt.body)
+			expectStatus(t, w, http.StatusBadRequest)
+			if msg := errorMessage(t, w); msg != tt.want {
+				t.Errorf("error = %q, want %q", msg, tt.want)
+			}
+		})
+	}
+
+	// Nothing was written.
+	w := request(t, http.MethodGet, path, f.AliceToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[Task](t, w); got.Title != "Task" || got.Status != "todo" {
+		t.Errorf("task = %q/%q after rejected updates, want Task/todo", got.Title, got.Status)
+	}
+}
+
+func TestTaskPayloadsHidePasswords(t *testing.T) {
+	f := setup(t)
+	since := latestSeq()
+	root := createTask(t, f.AliceToken, map[string]interface{}{"title": "Root", "assignee_id": f.Bob.ID})
+	child := createTask(t, f.AliceToken, map[string]interface{}{"title": "Child", "parent_id": root.ID, "assignee_id": f.Bob.ID})
+	updateTask(t, f.AliceToken, child.ID, map[string]interface{}{"status": "in_progress"})
+
+	for _, path := range []string{
+		"/api/v1/tasks",
+		fmt.Sprintf("/api/v1/tasks/%d", root.ID),
+		fmt.Sprintf("/api/v1/tasks/%d/subtasks", root.ID),
+	} {
+		w := request(t, http.MethodGet, path, f.AliceToken, nil)
+		expectStatus(t, w, http.StatusOK)
+		if body := w.Body.String(); strings.Contains(body, "password") || strings.Contains(body, "$2a$") {
+			t.Errorf("GET %s leaks a password: %s", path, body)
+		}
+	}
+
+	eventually(t, "the outbox to drain", outboxDrained)
+	var outbox []database.OutboxEvent
+	database.DB.Find(&outbox)
+	var events []database.Event
+	database.DB.Where("id > ?", since).Find(&events)
+	if len(outbox) == 0 || len(events) == 0 {
+		t.Fatalf("got %d outbox rows and %d logged events, want some of each", len(outbox), len(events))
+	}
+	for _, event := range outbox {
+		if strings.Contains(event.Payload, "password") || strings.Contains(event.Payload, "$2a$") {
+			t.Errorf("outbox %s payload leaks a password: %s", event.Type, event.Payload)
+		}
+	}
+	for _, event := range events {
+		if strings.Contains(event.Payload, "password") || strings.Contains(event.Payload, "$2a$") {
+			t.Errorf("logged %s payload leaks a password: %s", event.Type, event.Payload)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ziggler_backend/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TaskWatcher = database.TaskWatcher
+
+// watchTask makes the given users watch the task within tx. Users already
+// watching it are left alone; nil IDs are skipped.
+func watchTask(tx *gorm.DB, taskID uint, userIDs ...*uint) error {
+	for _, userID := range userIDs {
+		if userID == nil {
+			continue
+		}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&TaskWatcher{TaskID: taskID, UserID: *userID}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func taskWatcherIDs(db *gorm.DB, taskID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&TaskWatcher{}).Where("task_id = ?", taskID).Order("user_id").Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func taskWatchers(db *gorm.DB, taskID uint) ([]User, error) {
+	watchers := []User{}
+	err := publicUserFields(db).
+		Where("id IN (?)", db.Model(&TaskWatcher{}).Select("user_id").Where("task_id = ?", taskID)).
+		Order("id").
+		Find(&watchers).Error
+	return watchers, err
+}
+
+// changedTaskFields names the fields a watcher would care about that differ
+// between before and after.
+func changedTaskFields(before, after Task) []string {
+	var fields []string
+	if before.Title != after.Title {
+		fields = append(fields, "title")
+	}
+	if before.Description != after.Description {
+		fields = append(fields, "description")
+	}
+	if before.Status != after.Status {
+		fields = append(fields, "status")
+	}
+	if !sameID(before.AssigneeID, after.AssigneeID) {
+		fields = append(fields, "assignee")
+	}
+	if !sameID(before.ParentID, after.ParentID) {
+		fields = append(fields, "parent")
+	}
+	if !sameDueDate(before, after) {
+		fields = append(fields, "due date")
+	}
+	return fields
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameDueDate(before, after Task) bool {
+	if before.DueDate == nil || after.DueDate == nil {
+		return before.DueDate == after.DueDate
+	}
+	return before.DueDate.Equal(*after.DueDate)
+}
+
+// notifyWatchers sends a notification to every watcher of the task except
+// those in skip, who were already told about the change more specifically.
+func notifyWatchers(tx *gorm.DB, actorID uint, task Task, notificationType, message string, skip map[uint]bool) error {
+	ids, err := taskWatcherIDs(tx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if skip[id] {
+			continue
+		}
+		err := notify(tx, Notification{
+			UserID:  id,
+			ActorID: &actorID,
+			TaskID:  &task.ID,
+			Type:    notificationType,
+			Message: message,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyTaskDeleted tells the task's watchers that it was deleted.
+func notifyTaskDeleted(tx *gorm.DB, actorID uint, task Task) error {
+	var actor User
+	if err := tx.First(&actor, actorID).Error; err != nil {
+		return err
+	}
+	message := fmt.Sprintf("%s deleted \"%s\"", displayName(actor), task.Title)
+	return notifyWatchers(tx, actorID, task, database.NotificationTypeTaskDeleted, message, nil)
+}
+
+func describeTaskChange(actorName string, before, after Task) string {
+	fields := changedTaskFields(before, after)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s updated the %s of \"%s\"", actorName, strings.Join(fields, ", "), after.Title)
+}
+
+func GetTaskWatchers(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task Task
+	if err := dbFor(c).First(&task, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	watchers, err := taskWatchers(dbFor(c), task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch watchers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task_id": task.ID, "watchers": watchers})
+}
+
+func WatchTask(c *gin.Context) {
+	setWatching(c, true)
+}
+
+func UnwatchTask(c *gin.Context) {
+	setWatching(c, false)
+}
+
+// setWatching adds or removes the current user as a watcher and tells the
+// task's viewers about the new watcher list.
+func setWatching(c *gin.Context, watching bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	uid := uint(userID.(int))
+
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task Task
+	if err := dbFor(c).First(&task, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	var watchers []User
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if watching {
+			if err := watchTask(tx, task.ID, &uid); err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Where("task_id = ? AND user_id = ?", task.ID, uid).Delete(&TaskWatcher{}).Error; err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if watchers, err = taskWatchers(tx, task.ID); err != nil {
+			return err
+		}
+		return enqueueEvent(tx, WSMessage{
+			Type: "task_watchers_changed",
+			Payload: map[string]interface{}{
+				"task_id":  task.ID,
+				"watchers": watchers,
+			},
+			topics: []string{TaskTopic(task.ID)},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update watchers"})
+		return
+	}
+	wakeOutbox()
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":  task.ID,
+		"watching": watching,
+		"watchers": watchers,
+	})
+}
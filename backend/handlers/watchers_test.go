@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"ziggler_backend/database"
+)
+
+type watchersResponse struct {
+	TaskID   uint   `json:"task_id"`
+	Watching bool   `json:"watching"`
+	Watchers []User `json:"watchers"`
+}
+
+func watcherIDs(watchers []User) []uint {
+	ids := []uint{}
+	for _, user := range watchers {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func TestTaskWatchers(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Watched", "assignee_id": f.Bob.ID})
+
+	// The creator and the assignee watch the task from the start.
+	w := request(t, http.MethodGet, fmt.Sprintf("/api/v1/tasks/%d/watchers", task.ID), f.AdminToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	got := decode[watchersResponse](t, w)
+	if want := []uint{f.Alice.ID, f.Bob.ID}; fmt.Sprint(watcherIDs(got.Watchers)) != fmt.Sprint(want) {
+		t.Errorf("watchers = %v, want %v", watcherIDs(got.Watchers), want)
+	}
+	if got.Watchers[0].Email != "" {
+		t.Errorf("watcher %+v exposes an email", got.Watchers[0])
+	}
+
+	conn := dialWebSocket(t, f.AliceToken, TaskTopic(task.ID))
+
+	w = request(t, http.MethodPost, fmt.Sprintf("/api/v1/tasks/%d/watch", task.ID), f.AdminToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	got = decode[watchersResponse](t, w)
+	if !got.Watching || len(got.Watchers) != 3 {
+		t.Errorf("watch = %+v, want Admin added as the third watcher", got)
+	}
+	event := expectEvent(t, conn, "task_watchers_changed")
+	var changed watchersResponse
+	if err := json.Unmarshal(event.Payload, &changed); err != nil {
+		t.Fatal(err)
+	}
+	if changed.TaskID != task.ID || len(changed.Watchers) != 3 {
+		t.Errorf("task_watchers_changed = %+v, want 3 watchers of task %d", changed, task.ID)
+	}
+
+	// Watching twice is harmless.
+	w = request(t, http.MethodPost, fmt.Sprintf("/api/v1/tasks/%d/watch", task.ID), f.AdminToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[watchersResponse](t, w); len(got.Watchers) != 3 {
+		t.Errorf("watchers after watching twice = %v", watcherIDs(got.Watchers))
+	}
+
+	w = request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d/watch", task.ID), f.BobToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	got = decode[watchersResponse](t, w)
+	if want := []uint{f.Admin.ID, f.Alice.ID}; got.Watching || fmt.Sprint(watcherIDs(got.Watchers)) != fmt.Sprint(want) {
+		t.Errorf("unwatch = %+v, want watchers %v", got, want)
+	}
+
+	w = request(t, http.MethodPost, "/api/v1/tasks/9999/watch", f.BobToken, nil)
+	expectStatus(t, w, http.StatusNotFound)
+	w = request(t, http.MethodGet, "/api/v1/tasks/abc/watchers", f.BobToken, nil)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestWatchersNotifiedOfChanges(t *testing.T) {
+	f := setup(t)
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "Plan"})
+	w := request(t, http.MethodPost, fmt.Sprintf("/api/v1/tasks/%d/watch", task.ID), f.BobToken, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	updateTask(t, f.AliceToken, task.ID, map[string]interface{}{"title": "Plan v2", "description": "Details"})
+	list := listNotifications(t, f.BobToken, "")
+	if list.Total != 1 || list.Data[0].Type != database.NotificationTypeTaskUpdated {
+		t.Fatalf("Bob's notifications = %+v, want one task update", list.Data)
+	}
+	if want := `Alice updated the title, description of "Plan v2"`; list.Data[0].Message != want {
+		t.Errorf("message = %q, want %q", list.Data[0].Message, want)
+	}
+
+	// Bob stops watching, so the deletion is not his business.
+	w = request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d/watch", task.ID), f.BobToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	w = request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", task.ID), f.AdminToken, nil)
+	expectStatus(t, w, http.StatusNoContent)
+	if got := listNotifications(t, f.BobToken, ""); got.Total != 1 {
+		t.Errorf("Bob has %d notifications after unwatching, want 1", got.Total)
+	}
+
+	// Alice still watches and hears about it.
+	list = listNotifications(t, f.AliceToken, "")
+	if list.Total != 1 || list.Data[0].Type != database.NotificationTypeTaskDeleted {
+		t.Errorf("Alice's notifications = %+v, want the deletion", list.Data)
+	}
+}
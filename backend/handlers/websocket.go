@@ -1,14 +1,53 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"ziggler_backend/auth"
+	"ziggler_backend/pubsub"
+	"ziggler_backend/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"gorm.io/gorm"
+)
+
+// Topics a client can subscribe to. Every task event is published to
+// TopicTasks, the task's own topic, the topic of its root task (project)
+// and the assigned topic of its assignee.
+const (
+	TopicTasks = "tasks"
+	TopicStats = "stats"
+)
+
+const (
+	// Time allowed to write a single message to the peer.
+	wsWriteWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer.
+	wsPongWait = 60 * time.Second
+
+	// Send pings to the peer with this period. Must be less than wsPongWait.
+	wsPingPeriod = (wsPongWait * 9) / 10
+
+	// Maximum size of a message read from the peer.
+	wsMaxMessageSize = 4096
+
+	// Messages buffered per client before it is considered a slow consumer
+	// and disconnected.
+	wsSendQueueSize = 256
 )
 
 var (
@@ -17,99 +56,778 @@ var (
 			return true // Allow all origins
 		},
 	}
-	clients   = make(map[*websocket.Conn]bool)
-	clientsMu sync.Mutex
-	broadcast = make(chan WSMessage, 100)
+	clients       = make(map[*hubClient]bool)
+	subscriptions = make(map[string]map[*hubClient]bool)
+	clientsMu     sync.Mutex
+	broadcast     = make(chan WSMessage, 100)
+
+	// deliveryMu serialises fan-out with the start and end of replays so a
+	// resuming client never sees an event twice or out of order.
+	deliveryMu sync.Mutex
+
+	hubDone     = make(chan struct{})
+	hubStopOnce sync.Once
+	writers     sync.WaitGroup
+
+	// hubWorkers tracks the hub's background goroutines so shutdown can
+	// wait for them before the database is closed.
+	hubWorkers sync.WaitGroup
+
+	hubPubSub pubsub.PubSub
+
+	// replicaID identifies this process to the other replicas.
+	replicaID = func() string {
+		hostname, _ := os.Hostname()
+		return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	}()
+	stopRelay context.CancelFunc
 )
 
 type WSMessage struct {
+	Seq     uint64      `json:"seq,omitempty"`
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload"`
+
+	// topics is only used for routing and is never sent to clients.
+	topics []string
+
+	// ephemeral messages such as presence are not logged or sequenced.
+	ephemeral bool
+
+	// ctx carries the trace of the change that produced the message.
+	ctx context.Context
+}
+
+func (m WSMessage) traceContext() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// hubClient is a subscriber of the hub. WebSocket clients have a conn and a
+// writePump; SSE clients leave conn nil and drain send themselves.
+type hubClient struct {
+	conn       *websocket.Conn
+	remoteAddr string
+	userID     int
+	topics     map[string]bool
+
+	// resumeFrom is the last sequence number the client saw before
+	// reconnecting. Missed events are replayed on its first subscribe.
+	resumeFrom    uint64
+	resumePending bool
+
+	// userTopicPending defers the user topic of a resuming client until the
+	// replay, so its missed and live events arrive once and in order.
+	userTopicPending bool
+
+	// replayedThrough is the highest sequence number covered by the replay.
+	// Relayed events up to it are skipped. Guarded by deliveryMu.
+	replayedThrough uint64
+
+	// While replaying, live events are held back until the missed ones have
+	// been sent. Guarded by deliveryMu.
+	replaying bool
+	held      []WSMessage
+
+	// ctx carries the request fields of the connection's HTTP request for
+	// logging.
+	ctx context.Context
+
+	send      chan WSMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	closeCode int
+	closeText string
+}
+
+func newHubClient(ctx context.Context, conn *websocket.Conn, remoteAddr string) *hubClient {
+	return &hubClient{
+		ctx:        ctx,
+		conn:       conn,
+		remoteAddr: remoteAddr,
+		topics:     make(map[string]bool),
+		send:       make(chan WSMessage, wsSendQueueSize),
+		done:       make(chan struct{}),
+		closeCode:  websocket.CloseNormalClosure,
+	}
+}
+
+// enqueue hands a message to the client's writer without blocking. A client
+// whose queue is full is disconnected rather than allowed to hold up the hub.
+func (client *hubClient) enqueue(msg WSMessage) bool {
+	select {
+	case <-client.done:
+		return false
+	default:
+	}
+
+	select {
+	case client.send <- msg:
+		return true
+	default:
+		slog.WarnContext(client.ctx, "Realtime client too slow, disconnecting", "remote_addr", client.remoteAddr)
+		client.close(websocket.ClosePolicyViolation, "send queue full")
+		return false
+	}
+}
+
+// close asks the writer to send a close frame and shut the connection down.
+// It is safe to call more than once and from any goroutine.
+func (client *hubClient) close(code int, text string) {
+	client.closeOnce.Do(func() {
+		client.closeCode = code
+		client.closeText = text
+		close(client.done)
+	})
+}
+
+// flush writes whatever is still queued, sharing a single write deadline, so
+// messages such as auth errors reach the peer before the close frame.
+func (client *hubClient) flush() {
+	client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	for {
+		select {
+		case msg := <-client.send:
+			if err := client.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
 }
 
-func InitWebSocket() {
+// writePump is the only goroutine that writes to the connection. It drains
+// the send queue, keeps the connection alive with pings and sends a close
+// frame once the client is closed.
+func (client *hubClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+		writers.Done()
+	}()
+
+	for {
+		select {
+		case msg := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := client.conn.WriteJSON(msg); err != nil {
+				slog.WarnContext(client.ctx, "WebSocket write error", "remote_addr", client.remoteAddr, "error", err)
+				client.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				client.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+
+		case <-client.done:
+			if client.closeCode != websocket.CloseAbnormalClosure {
+				client.flush()
+				client.conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(client.closeCode, client.closeText),
+					time.Now().Add(wsWriteWait),
+				)
+			}
+			return
+		}
+	}
+}
+
+type wsClientMessage struct {
+	Type       string   `json:"type"`
+	Token      string   `json:"token,omitempty"`
+	Topic      string   `json:"topic,omitempty"`
+	Topics     []string `json:"topics,omitempty"`
+	ResumeFrom *uint64  `json:"resume_from,omitempty"`
+	TaskID     uint     `json:"task_id,omitempty"`
+}
+
+func TaskTopic(taskID uint) string {
+	return fmt.Sprintf("task:%d", taskID)
+}
+
+func ProjectTopic(rootTaskID uint) string {
+	return fmt.Sprintf("project:%d", rootTaskID)
+}
+
+func UserAssignedTopic(userID uint) string {
+	return fmt.Sprintf("user:%d:assigned", userID)
+}
+
+func validTopic(topic string) bool {
+	if topic == TopicTasks || topic == TopicStats || topic == TopicPresence {
+		return true
+	}
+
+	parts := strings.Split(topic, ":")
+	switch {
+	case len(parts) == 2 && (parts[0] == "task" || parts[0] == "project"):
+		_, err := strconv.ParseUint(parts[1], 10, 32)
+		return err == nil
+	case len(parts) == 3 && parts[0] == "user" && parts[2] == "assigned":
+		_, err := strconv.ParseUint(parts[1], 10, 32)
+		return err == nil
+	}
+	return false
+}
+
+// InitWebSocket starts the hub. Events are relayed through ps so that every
+// replica sharing the broker delivers every event to its own clients, and
+// presence is shared through store.
+func InitWebSocket(ps pubsub.PubSub, store pubsub.Presence) error {
+	hubPubSub = ps
+	presenceStore = store
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := ps.Subscribe(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	stopRelay = cancel
+
+	hubWorkers.Add(3)
 	go handleBroadcast()
-	log.Printf("WebSocket server initialized")
+	go relayEvents(events)
+	go sweepPresence()
+	slog.Info("WebSocket server initialized")
+	return nil
+}
+
+// hubEnvelope is the wire format of a hub event on the pub/sub broker.
+type hubEnvelope struct {
+	Seq       uint64          `json:"seq,omitempty"`
+	Type      string          `json:"type"`
+	Topics    []string        `json:"topics"`
+	Ephemeral bool            `json:"ephemeral,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+
+	// TraceParent lets every replica continue the trace of the event.
+	TraceParent string `json:"traceparent,omitempty"`
 }
 
+// handleBroadcast hands locally published ephemeral messages to the broker.
+// Sequenced events are published by the outbox dispatcher through hubSink.
 func handleBroadcast() {
+	defer hubWorkers.Done()
+
 	for {
-		msg := <-broadcast
-		clientsMu.Lock()
-		for client := range clients {
-			err := client.WriteJSON(msg)
-			if err != nil {
-				log.Printf("WebSocket write error: %v", err)
-				client.Close()
-				delete(clients, client)
+		select {
+		case msg := <-broadcast:
+			broadcastMessage(msg)
+		case <-hubDone:
+			// Publish what is already queued before the broker goes away.
+			for {
+				select {
+				case msg := <-broadcast:
+					broadcastMessage(msg)
+				default:
+					return
+				}
 			}
 		}
-		clientsMu.Unlock()
 	}
 }
 
+// broadcastMessage logs a message that is not ephemeral, which gives it its
+// sequence number, and hands it to the broker. If the broker is unavailable
+// the message is still delivered to this replica's clients. It only fails if
+// the message could not be logged, in which case it was not sent either.
+func broadcastMessage(msg WSMessage) error {
+	ctx, span := tracing.Start(msg.traceContext(), "hub.broadcast", attribute.String("event.type", msg.Type))
+	defer span.End()
+	msg.ctx = ctx
+
+	if !msg.ephemeral {
+		seq, err := appendEvent(msg)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("logging event: %w", err)
+		}
+		msg.Seq = seq
+		span.SetAttributes(attribute.Int64("event.seq", int64(seq)))
+	}
+
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode event", "type", msg.Type, "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil
+	}
+	data, _ := json.Marshal(hubEnvelope{
+		Seq:         msg.Seq,
+		Type:        msg.Type,
+		Topics:      msg.topics,
+		Ephemeral:   msg.ephemeral,
+		Payload:     payload,
+		TraceParent: tracing.Inject(ctx),
+	})
+
+	publishCtx, cancel := context.WithTimeout(ctx, wsWriteWait)
+	err = hubPubSub.Publish(publishCtx, data)
+	cancel()
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to publish event, delivering locally", "type", msg.Type, "error", err)
+		span.RecordError(err)
+		deliver(msg)
+	}
+	return nil
+}
+
+// relayEvents delivers every event received from the broker to the local
+// subscribers of its topics.
+func relayEvents(events <-chan []byte) {
+	defer hubWorkers.Done()
+
+	for data := range events {
+		var envelope hubEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			slog.Error("Failed to decode relayed event", "error", err)
+			continue
+		}
+
+		deliver(WSMessage{
+			Seq:       envelope.Seq,
+			Type:      envelope.Type,
+			Payload:   envelope.Payload,
+			topics:    envelope.Topics,
+			ephemeral: envelope.Ephemeral,
+			ctx:       tracing.Extract(context.Background(), envelope.TraceParent),
+		})
+	}
+}
+
+func deliver(msg WSMessage) {
+	_, span := tracing.Start(msg.traceContext(), "hub.deliver",
+		attribute.String("event.type", msg.Type),
+		attribute.Int64("event.seq", int64(msg.Seq)),
+	)
+	defer span.End()
+
+	if msg.Type == "stats_changed" {
+		invalidateStats()
+	}
+
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+
+	clientsMu.Lock()
+	matched := recipients(msg.topics)
+	clientsMu.Unlock()
+	span.SetAttributes(attribute.Int("hub.recipients", len(matched)))
+
+	for client := range matched {
+		if client.replaying {
+			if len(client.held) == wsSendQueueSize {
+				slog.WarnContext(client.ctx, "Realtime client too slow, disconnecting", "remote_addr", client.remoteAddr)
+				client.close(websocket.ClosePolicyViolation, "send queue full")
+				continue
+			}
+			client.held = append(client.held, msg)
+			continue
+		}
+		// Already sent to this client as part of a replay.
+		if msg.Seq != 0 && msg.Seq <= client.replayedThrough {
+			continue
+		}
+		client.enqueue(msg)
+	}
+}
+
+// publish queues a message for the hub without ever blocking the caller, so
+// HTTP handlers are not held up by WebSocket delivery.
+func publish(msg WSMessage) {
+	select {
+	case <-hubDone:
+		return
+	default:
+	}
+
+	select {
+	case broadcast <- msg:
+	default:
+		slog.Warn("WebSocket broadcast queue full, dropping message", "type", msg.Type)
+	}
+}
+
+// DisconnectClients sends a close frame to every WebSocket client and ends
+// every event stream. The HTTP server runs it as soon as it starts shutting
+// down, since it does not wait for hijacked connections and would otherwise
+// wait on open streams until its deadline.
+func DisconnectClients() {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	for client := range clients {
+		client.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// ShutdownWebSocket stops the hub. The broadcast loop publishes whatever is
+// still queued and exits with the hub's other goroutines, then every client
+// is disconnected. It waits for all of them to finish or ctx to expire.
+func ShutdownWebSocket(ctx context.Context) error {
+	hubStopOnce.Do(func() {
+		close(hubDone)
+		if stopRelay != nil {
+			stopRelay()
+		}
+	})
+
+	if err := waitFor(ctx, &hubWorkers); err != nil {
+		return err
+	}
+	if hubPubSub != nil {
+		hubPubSub.Close()
+	}
+	if presenceStore != nil {
+		if err := presenceStore.Remove(ctx, replicaID); err != nil {
+			slog.Warn("Failed to remove presence", "error", err)
+		}
+		presenceStore.Close()
+	}
+
+	DisconnectClients()
+	if err := waitFor(ctx, &writers); err != nil {
+		return err
+	}
+
+	slog.Info("WebSocket server stopped")
+	return nil
+}
+
+// waitFor waits for wg or until ctx expires.
+func waitFor(ctx context.Context, wg *sync.WaitGroup) error {
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recipients returns every client subscribed to at least one of the given
+// topics. Callers must hold clientsMu.
+func recipients(topics []string) map[*hubClient]bool {
+	matched := make(map[*hubClient]bool)
+	for _, topic := range topics {
+		for client := range subscriptions[topic] {
+			matched[client] = true
+		}
+	}
+	return matched
+}
+
+func subscribe(client *hubClient, topic string) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if subscriptions[topic] == nil {
+		subscriptions[topic] = make(map[*hubClient]bool)
+	}
+	subscriptions[topic][client] = true
+	client.topics[topic] = true
+}
+
+func unsubscribe(client *hubClient, topic string) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	delete(client.topics, topic)
+	if subs, ok := subscriptions[topic]; ok {
+		delete(subs, client)
+		if len(subs) == 0 {
+			delete(subscriptions, topic)
+		}
+	}
+}
+
+// removeClient drops the client and all of its subscriptions. Callers must
+// hold clientsMu.
+func removeClient(client *hubClient) {
+	for topic := range client.topics {
+		if subs, ok := subscriptions[topic]; ok {
+			delete(subs, client)
+			if len(subs) == 0 {
+				delete(subscriptions, topic)
+			}
+		}
+	}
+	delete(clients, client)
+}
+
 func WebSocketHandler(c *gin.Context) {
+	select {
+	case <-hubDone:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+		return
+	default:
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		slog.WarnContext(c.Request.Context(), "WebSocket upgrade error", "error", err)
 		return
 	}
-	defer conn.Close()
+
+	client := newHubClient(c.Request.Context(), conn, c.Request.RemoteAddr)
+	if resumeFrom, err := strconv.ParseUint(c.Query("resume_from"), 10, 64); err == nil {
+		client.resumeFrom = resumeFrom
+		client.resumePending = true
+	}
 
 	// Register client
 	clientsMu.Lock()
-	clients[conn] = true
+	clients[client] = true
 	clientsMu.Unlock()
 
-	log.Printf("WebSocket client connected from %s", c.Request.RemoteAddr)
+	writers.Add(1)
+	go client.writePump()
+
+	defer func() {
+		clientsMu.Lock()
+		removeClient(client)
+		clientsMu.Unlock()
+		untrackConnection(client, client.userID)
+		client.close(websocket.CloseNormalClosure, "")
+		slog.InfoContext(client.ctx, "WebSocket client disconnected", "remote_addr", client.remoteAddr, "user_id", client.userID)
+	}()
+
+	slog.InfoContext(client.ctx, "WebSocket client connected", "remote_addr", client.remoteAddr)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
 
 	// Send welcome message
-	conn.WriteJSON(WSMessage{
+	client.enqueue(WSMessage{
 		Type: "connected",
 		Payload: map[string]interface{}{
-			"message": "Connected to WebSocket server",
+			"message":    "Connected to WebSocket server",
+			"latest_seq": latestSeq(),
 		},
 	})
 
 	// Listen for messages from this client
 	for {
-		var msg map[string]interface{}
+		var msg wsClientMessage
 		err := conn.ReadJSON(&msg)
 		if err != nil {
-			log.Printf("WebSocket read error: %v", err)
-			break
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.WarnContext(client.ctx, "WebSocket read error", "remote_addr", client.remoteAddr, "error", err)
+			}
+			return
 		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
 
-		// Handle authentication
-		if msgType, ok := msg["type"].(string); ok && msgType == "authenticate" {
-			if token, ok := msg["token"].(string); ok {
-				claims, err := auth.ValidateToken(token)
-				if err != nil {
-					conn.WriteJSON(WSMessage{
-						Type:    "auth_error",
-						Payload: "Invalid token",
-					})
-					break
-				}
-
-				log.Printf("WebSocket client authenticated: user %d", claims.UserID)
-				conn.WriteJSON(WSMessage{
-					Type: "authenticated",
-					Payload: map[string]interface{}{
-						"user_id": claims.UserID,
-						"message": "Successfully authenticated",
-					},
+		switch msg.Type {
+		case "authenticate":
+			if msg.Token == "" {
+				continue
+			}
+			claims, err := auth.ValidateToken(msg.Token)
+			if err != nil {
+				client.enqueue(WSMessage{
+					Type:    "auth_error",
+					Payload: "Invalid token",
 				})
+				client.close(websocket.ClosePolicyViolation, "invalid token")
+				return
+			}
+
+			if client.userID != 0 && client.userID != claims.UserID {
+				untrackConnection(client, client.userID)
+				unsubscribe(client, UserTopic(uint(client.userID)))
 			}
+			client.userID = claims.UserID
+			trackConnection(client, claims.UserID)
+			if client.resumePending {
+				client.userTopicPending = true
+			} else {
+				subscribe(client, UserTopic(uint(claims.UserID)))
+			}
+			slog.InfoContext(client.ctx, "WebSocket client authenticated", "remote_addr", client.remoteAddr, "user_id", claims.UserID)
+			client.enqueue(WSMessage{
+				Type: "authenticated",
+				Payload: map[string]interface{}{
+					"user_id": claims.UserID,
+					"message": "Successfully authenticated",
+				},
+			})
+
+		case "subscribe", "unsubscribe":
+			handleSubscription(client, msg)
+
+		case PresenceViewing, PresenceEditing, "leave":
+			handlePresence(client, msg)
 		}
 	}
+}
+
+func handleSubscription(client *hubClient, msg wsClientMessage) {
+	if client.userID == 0 {
+		client.enqueue(WSMessage{
+			Type:    "subscription_error",
+			Payload: "Authentication required",
+		})
+		return
+	}
 
-	// Unregister client
+	topics := msg.Topics
+	if msg.Topic != "" {
+		topics = append(topics, msg.Topic)
+	}
+
+	if msg.ResumeFrom != nil {
+		client.resumeFrom = *msg.ResumeFrom
+		client.resumePending = true
+	}
+
+	replay := msg.Type == "subscribe" && client.resumePending
+	if replay {
+		holdLiveEvents(client)
+	}
+	if replay && client.userTopicPending {
+		client.userTopicPending = false
+		subscribe(client, UserTopic(uint(client.userID)))
+	}
+
+	var applied, invalid []string
+	for _, topic := range topics {
+		if !validTopic(topic) {
+			invalid = append(invalid, topic)
+			continue
+		}
+		if msg.Type == "subscribe" {
+			subscribe(client, topic)
+		} else {
+			unsubscribe(client, topic)
+		}
+		applied = append(applied, topic)
+	}
+
+	if len(invalid) > 0 {
+		client.enqueue(WSMessage{
+			Type: "subscription_error",
+			Payload: map[string]interface{}{
+				"message": "Invalid topics",
+				"topics":  invalid,
+			},
+		})
+	}
+
+	if len(applied) > 0 {
+		client.enqueue(WSMessage{
+			Type: msg.Type + "d",
+			Payload: map[string]interface{}{
+				"topics": applied,
+			},
+		})
+	}
+
+	if replay {
+		client.resumePending = false
+		replayMissed(client)
+	}
+}
+
+func handlePresence(client *hubClient, msg wsClientMessage) {
+	if client.userID == 0 {
+		client.enqueue(WSMessage{
+			Type:    "presence_error",
+			Payload: "Authentication required",
+		})
+		return
+	}
+
+	if msg.Type == "leave" {
+		setPresence(client, client.userID, 0, "")
+		return
+	}
+
+	if msg.TaskID == 0 {
+		client.enqueue(WSMessage{
+			Type:    "presence_error",
+			Payload: "task_id is required",
+		})
+		return
+	}
+
+	setPresence(client, client.userID, msg.TaskID, msg.Type)
+}
+
+// holdLiveEvents makes deliver hold the client's live events back until
+// replayMissed has sent the missed ones. Call it before subscribing the
+// client, so no event sent after the subscription can slip past the replay.
+func holdLiveEvents(client *hubClient) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	client.replaying = true
+}
+
+// replayMissed sends the events the client missed since resumeFrom on the
+// topics it is subscribed to, or resync_required if they are no longer
+// available, followed by the live events held back meanwhile. The missed
+// events are loaded before taking deliveryMu, so a slow query does not hold
+// up delivery to every other client. Events delivered during the query are
+// held, and those the query also found are skipped by sequence number.
+func replayMissed(client *hubClient) {
 	clientsMu.Lock()
-	delete(clients, conn)
+	topics := make(map[string]bool, len(client.topics))
+	for topic := range client.topics {
+		topics[topic] = true
+	}
 	clientsMu.Unlock()
-	log.Printf("WebSocket client disconnected")
+
+	missed, through, err := missedEvents(client.resumeFrom, topics)
+	if err != nil {
+		if !errors.Is(err, errResyncRequired) {
+			slog.ErrorContext(client.ctx, "Failed to load missed events", "error", err)
+		}
+		through = latestSeq()
+		missed = []WSMessage{{
+			Type: "resync_required",
+			Payload: map[string]interface{}{
+				"message":    "Missed events are no longer available, reload state",
+				"latest_seq": through,
+			},
+		}}
+	}
+
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+
+	client.replayedThrough = through
+	for _, msg := range missed {
+		client.enqueue(msg)
+	}
+	for _, msg := range client.held {
+		if msg.Seq == 0 || msg.Seq > through {
+			client.enqueue(msg)
+		}
+	}
+	client.held = nil
+	client.replaying = false
 }
 
 func WebSocketMiddleware() gin.HandlerFunc {
@@ -120,25 +838,80 @@ func HandleWebSocket(c *gin.Context) {
 	WebSocketHandler(c)
 }
 
-func BroadcastTaskCreated(task Task) {
-	broadcast <- WSMessage{
+// rootTaskID walks up the parent chain and returns the ID of the top-level
+// task, which identifies the project a task belongs to.
+func rootTaskID(db *gorm.DB, task Task) uint {
+	id, parentID := task.ID, task.ParentID
+	seen := map[uint]bool{id: true}
+	for parentID != nil && *parentID != 0 && !seen[*parentID] {
+		var parent Task
+		if err := db.Select("id", "parent_id").First(&parent, *parentID).Error; err != nil {
+			break
+		}
+		seen[parent.ID] = true
+		id, parentID = parent.ID, parent.ParentID
+	}
+	return id
+}
+
+// taskTopics lists the topics a task event goes to, including the private
+// topic of every watcher so they see changes without subscribing.
+func taskTopics(db *gorm.DB, task Task, assigneeIDs ...*uint) []string {
+	topics := []string{TopicTasks, TaskTopic(task.ID), ProjectTopic(rootTaskID(db, task))}
+	for _, assigneeID := range assigneeIDs {
+		if assigneeID != nil {
+			topics = append(topics, UserAssignedTopic(*assigneeID))
+		}
+	}
+	if watcherIDs, err := taskWatcherIDs(db, task.ID); err == nil {
+		for _, id := range watcherIDs {
+			topics = append(topics, UserTopic(id))
+		}
+	}
+	return topics
+}
+
+// enqueueTaskEvent writes a task event and the matching stats_changed
+// notification to the outbox within tx.
+func enqueueTaskEvent(tx *gorm.DB, msg WSMessage, taskID uint) error {
+	if err := enqueueEvent(tx, msg); err != nil {
+		return err
+	}
+	return enqueueEvent(tx, WSMessage{
+		Type: "stats_changed",
+		Payload: map[string]interface{}{
+			"task_id": taskID,
+		},
+		topics: []string{TopicStats},
+	})
+}
+
+// BroadcastTaskCreated records a task_created event in the outbox. It must
+// be called inside the transaction that creates the task.
+func BroadcastTaskCreated(tx *gorm.DB, task Task) error {
+	return enqueueTaskEvent(tx, WSMessage{
 		Type:    "task_created",
 		Payload: task,
-	}
+		topics:  taskTopics(tx, task, task.AssigneeID),
+	}, task.ID)
 }
 
-func BroadcastTaskUpdated(task Task) {
-	broadcast <- WSMessage{
+// BroadcastTaskUpdated also notifies the previous assignee so their
+// assigned list can drop a task that was handed to someone else.
+func BroadcastTaskUpdated(tx *gorm.DB, task Task, previousAssigneeID *uint) error {
+	return enqueueTaskEvent(tx, WSMessage{
 		Type:    "task_updated",
 		Payload: task,
-	}
+		topics:  taskTopics(tx, task, task.AssigneeID, previousAssigneeID),
+	}, task.ID)
 }
 
-func BroadcastTaskDeleted(taskID uint, assigneeID *uint) {
-	broadcast <- WSMessage{
+func BroadcastTaskDeleted(tx *gorm.DB, task Task) error {
+	return enqueueTaskEvent(tx, WSMessage{
 		Type: "task_deleted",
 		Payload: map[string]interface{}{
-			"id": taskID,
+			"id": task.ID,
 		},
-	}
+		topics: taskTopics(tx, task, task.AssigneeID),
+	}, task.ID)
 }
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ziggler_backend/database"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsEvent struct {
+	Seq     uint64          `json:"seq"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// connectWebSocket connects to the hub through a real HTTP server, with
+// query appended to the URL, and returns the connection and the latest_seq
+// of its connected message.
+func connectWebSocket(t *testing.T, query string) (*websocket.Conn, uint64) {
+	t.Helper()
+	server := httptest.NewServer(testRouter)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var connected struct {
+		LatestSeq uint64 `json:"latest_seq"`
+	}
+	if err := json.Unmarshal(expectEvent(t, conn, "connected").Payload, &connected); err != nil {
+		t.Fatal(err)
+	}
+	return conn, connected.LatestSeq
+}
+
+// dialWebSocket connects to the hub as the given user and subscribes to
+// topics.
+func dialWebSocket(t *testing.T, token string, topics ...string) *websocket.Conn {
+	t.Helper()
+	conn, _ := connectWebSocket(t, "")
+	send(t, conn, map[string]interface{}{"type": "authenticate", "token": token})
+	expectEvent(t, conn, "authenticated")
+	if len(topics) > 0 {
+		send(t, conn, map[string]interface{}{"type": "subscribe", "topics": topics})
+		expectEvent(t, conn, "subscribed")
+	}
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, msg interface{}) {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("send %v: %v", msg, err)
+	}
+}
+
+// expectEvent reads messages until one of the given type arrives, skipping
+// others such as stats_changed, and fails the test after 5 seconds.
+func expectEvent(t *testing.T, conn *websocket.Conn, eventType string) wsEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		var event wsEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("waiting for %s: %v", eventType, err)
+		}
+		if event.Type == eventType {
+			return event
+		}
+	}
+}
+
+func TestWebSocketTaskEvents(t *testing.T) {
+	f := setup(t)
+	conn := dialWebSocket(t, f.AliceToken, TopicTasks)
+
+	task := createTask(t, f.AdminToken, map[string]interface{}{"title": "Broadcast me"})
+	created := expectEvent(t, conn, "task_created")
+	var payload Task
+	if err := json.Unmarshal(created.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != task.ID || payload.Title != "Broadcast me" || payload.Creator.ID != f.Admin.ID {
+		t.Errorf("task_created payload = %+v, want task %d by admin", payload, task.ID)
+	}
+
+	updateTask(t, f.AdminToken, task.ID, map[string]interface{}{"status": "in_progress"})
+	updated := expectEvent(t, conn, "task_updated")
+	if err := json.Unmarshal(updated.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Status != "in_progress" {
+		t.Errorf("task_updated status = %q, want in_progress", payload.Status)
+	}
+	if updated.Seq <= created.Seq {
+		t.Errorf("task_updated seq = %d, want after %d", updated.Seq, created.Seq)
+	}
+
+	w := request(t, http.MethodDelete, fmt.Sprintf("/api/v1/tasks/%d", task.ID), f.AdminToken, nil)
+	expectStatus(t, w, http.StatusNoContent)
+	deleted := expectEvent(t, conn, "task_deleted")
+	var deletedPayload struct {
+		ID uint `json:"id"`
+	}
+	if err := json.Unmarshal(deleted.Payload, &deletedPayload); err != nil {
+		t.Fatal(err)
+	}
+	if deletedPayload.ID != task.ID {
+		t.Errorf("task_deleted id = %d, want %d", deletedPayload.ID, task.ID)
+	}
+}
+
+func TestWebSocketTaskTopic(t *testing.T) {
+	f := setup(t)
+	watched := createTask(t, f.AdminToken, map[string]interface{}{"title": "Watched"})
+	other := createTask(t, f.AdminToken, map[string]interface{}{"title": "Other"})
+	conn := dialWebSocket(t, f.AliceToken, TaskTopic(watched.ID))
+
+	// Only the task the client subscribed to reaches it.
+	updateTask(t, f.AdminToken, other.ID, map[string]interface{}{"title": "Other, renamed"})
+	updateTask(t, f.AdminToken, watched.ID, map[string]interface{}{"title": "Watched, renamed"})
+
+	event := expectEvent(t, conn, "task_updated")
+	var payload Task
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != watched.ID {
+		t.Errorf("task_updated for task %d, want only task %d", payload.ID, watched.ID)
+	}
+}
+
+func TestWebSocketAssigneeNotification(t *testing.T) {
+	f := setup(t)
+	// Authenticating subscribes the client to its own user topic.
+	conn := dialWebSocket(t, f.BobToken)
+
+	task := createTask(t, f.AliceToken, map[string]interface{}{"title": "For Bob", "assignee_id": f.Bob.ID})
+	event := expectEvent(t, conn, "notification_created")
+	var notification Notification
+	if err := json.Unmarshal(event.Payload, &notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.UserID != f.Bob.ID || notification.TaskID == nil || *notification.TaskID != task.ID {
+		t.Errorf("notification = %+v, want one for Bob about task %d", notification, task.ID)
+	}
+}
+
+func TestWebSocketRejectsInvalidToken(t *testing.T) {
+	setup(t)
+	conn, _ := connectWebSocket(t, "")
+	send(t, conn, map[string]interface{}{"type": "subscribe", "topics": []string{TopicTasks}})
+	expectEvent(t, conn, "subscription_error")
+
+	send(t, conn, map[string]interface{}{"type": "authenticate", "token": "not-a-jwt"})
+	expectEvent(t, conn, "auth_error")
+}
+
+// readUntilTask reads every message up to and including the task_created
+// message for the task with the given title.
+func readUntilTask(t *testing.T, conn *websocket.Conn, title string) []wsEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var events []wsEvent
+	for {
+		var event wsEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("waiting for task %q: %v", title, err)
+		}
+		events = append(events, event)
+
+		var task Task
+		if event.Type == "task_created" && json.Unmarshal(event.Payload, &task) == nil && task.Title == title {
+			return events
+		}
+	}
+}
+
+func TestWebSocketResumeDeliversUserEventsOnce(t *testing.T) {
+	f := setup(t)
+	_, latest := connectWebSocket(t, "")
+
+	// Bob reconnects with resume_from and authenticates, then is assigned
+	// a task before his first subscribe.
+	conn, _ := connectWebSocket(t, fmt.Sprintf("?resume_from=%d", latest))
+	send(t, conn, map[string]interface{}{"type": "authenticate", "token": f.BobToken})
+	expectEvent(t, conn, "authenticated")
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "For Bob", "assignee_id": f.Bob.ID})
+	eventually(t, "the outbox to drain", outboxDrained)
+
+	send(t, conn, map[string]interface{}{"type": "subscribe", "topics": []string{TopicTasks}})
+	createTask(t, f.AliceToken, map[string]interface{}{"title": "Marker"})
+
+	var seqs []uint64
+	notifications := 0
+	for _, event := range readUntilTask(t, conn, "Marker") {
+		if event.Type == "notification_created" {
+			notifications++
+		}
+		if event.Seq != 0 {
+			seqs = append(seqs, event.Seq)
+		}
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Errorf("seqs = %v, want strictly increasing", seqs)
+			break
+		}
+	}
+	if notifications != 1 {
+		t.Errorf("got %d notification_created messages, want 1", notifications)
+	}
+}
+
+func TestWebSocketResumeReplaysMissedEvents(t *testing.T) {
+	f := setup(t)
+	_, last := connectWebSocket(t, "")
+
+	missed := createTask(t, f.AdminToken, map[string]interface{}{"title": "Missed"})
+	eventually(t, "the outbox to drain", outboxDrained)
+
+	conn, _ := connectWebSocket(t, fmt.Sprintf("?resume_from=%d", last))
+	send(t, conn, map[string]interface{}{"type": "authenticate", "token": f.AliceToken})
+	expectEvent(t, conn, "authenticated")
+	send(t, conn, map[string]interface{}{"type": "subscribe", "topics": []string{TopicTasks}})
+
+	events := readUntilTask(t, conn, "Missed")
+	replayed := events[len(events)-1]
+	var task Task
+	if err := json.Unmarshal(replayed.Payload, &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.ID != missed.ID || replayed.Seq <= last {
+		t.Errorf("replayed task %d at seq %d, want task %d after %d", task.ID, replayed.Seq, missed.ID, last)
+	}
+}
+
+func TestWebSocketResumeRequiresResyncAfterGap(t *testing.T) {
+	f := setup(t)
+	_, last := connectWebSocket(t, "")
+
+	createTask(t, f.AdminToken, map[string]interface{}{"title": "Trimmed"})
+	createTask(t, f.AdminToken, map[string]interface{}{"title": "Kept"})
+	eventually(t, "the outbox to drain", outboxDrained)
+
+	// The log was trimmed past the first event the client missed.
+	database.DB.Where("id <= ?", last+1).Delete(&database.Event{})
+
+	conn, _ := connectWebSocket(t, fmt.Sprintf("?resume_from=%d", last))
+	send(t, conn, map[string]interface{}{"type": "authenticate", "token": f.AliceToken})
+	expectEvent(t, conn, "authenticated")
+	send(t, conn, map[string]interface{}{"type": "subscribe", "topics": []string{TopicTasks}})
+	expectEvent(t, conn, "resync_required")
+}
+
+func TestReplayHoldsLiveEventsBack(t *testing.T) {
+	f := setup(t)
+	last := latestSeq()
+	createTask(t, f.AdminToken, map[string]interface{}{"title": "Missed"})
+	eventually(t, "the outbox to drain", outboxDrained)
+	through := latestSeq()
+
+	client := newHubClient(context.Background(), nil, "replay-test")
+	client.resumeFrom = last
+	holdLiveEvents(client)
+	subscribe(client, TopicTasks)
+	t.Cleanup(func() {
+		clientsMu.Lock()
+		removeClient(client)
+		clientsMu.Unlock()
+	})
+
+	// Delivered while the missed events are loaded: one the replay covers
+	// as well, and one after it.
+	deliver(WSMessage{Seq: through, Type: "task_created", Payload: map[string]string{}, topics: []string{TopicTasks}})
+	deliver(WSMessage{Seq: through + 1, Type: "test_live", Payload: map[string]string{}, topics: []string{TopicTasks}})
+	if len(client.send) != 0 {
+		t.Fatalf("%d live events sent before the replay", len(client.send))
+	}
+	replayMissed(client)
+
+	var seqs []uint64
+	for len(client.send) > 0 {
+		seqs = append(seqs, (<-client.send).Seq)
+	}
+	if len(seqs) < 2 || seqs[len(seqs)-1] != through+1 {
+		t.Fatalf("sent seqs %v, want the replay up to %d, then %d", seqs, through, through+1)
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			t.Fatalf("sent seqs %v, want strictly increasing", seqs)
+		}
+	}
+}
+
+func TestEventLogTrimsInBatches(t *testing.T) {
+	setup(t)
+	appendTestEvent := func() uint64 {
+		t.Helper()
+		seq, err := appendEvent(WSMessage{Type: "test_log", Payload: map[string]string{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return seq
+	}
+	countOlderThanLog := func(latest uint64) int64 {
+		var count int64
+		database.DB.Model(&database.Event{}).Where("id <= ?", latest-eventLogSize).Count(&count)
+		return count
+	}
+
+	// Fill the log with events of this test up to a trim, then up to just
+	// before the next one.
+	seq := appendTestEvent()
+	for appended := 1; appended <= eventLogSize || seq%eventLogTrimBatch != 0; appended++ {
+		seq = appendTestEvent()
+	}
+	for i := 1; i < eventLogTrimBatch; i++ {
+		seq = appendTestEvent()
+	}
+	if countOlderThanLog(seq) == 0 {
+		t.Fatal("the log was trimmed on every append")
+	}
+
+	seq = appendTestEvent()
+	if got := countOlderThanLog(seq); got != 0 {
+		t.Errorf("%d events older than the log size kept after a trim", got)
+	}
+}
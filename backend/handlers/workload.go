@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"ziggler_backend/config"
+	"ziggler_backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserWorkload struct {
+	UserID          uint   `json:"user_id"`
+	Username        string `json:"username"`
+	DisplayName     string `json:"display_name"`
+	OpenTasks       int64  `json:"open_tasks"`
+	TodoTasks       int64  `json:"todo_tasks"`
+	InProgressTasks int64  `json:"in_progress_tasks"`
+	Overloaded      bool   `json:"overloaded"`
+}
+
+// ReassignmentSuggestion proposes moving a todo task from an overloaded user
+// to a less loaded one.
+type ReassignmentSuggestion struct {
+	TaskID       uint   `json:"task_id"`
+	Title        string `json:"title"`
+	FromUserID   uint   `json:"from_user_id"`
+	FromUsername string `json:"from_username"`
+	ToUserID     uint   `json:"to_user_id"`
+	ToUsername   string `json:"to_username"`
+}
+
+type WorkloadResponse struct {
+	Threshold   int64                    `json:"threshold"`
+	Users       []UserWorkload           `json:"users"`
+	Suggestions []ReassignmentSuggestion `json:"suggestions"`
+	GeneratedAt time.Time                `json:"generated_at"`
+}
+
+func GetWorkload(c *gin.Context) {
+	_, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	threshold := int64(config.AppConfig.WorkloadThreshold)
+	if raw := c.Query("threshold"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid threshold"})
+			return
+		}
+		threshold = parsed
+	}
+
+	var users []User
+	if err := dbFor(c).Order("id").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	var rows []statusCount
+	if err := dbFor(c).Model(&Task{}).
+		Select("assignee_id, status, COUNT(*) AS count").
+		Where("assignee_id IS NOT NULL AND status IN ?", []string{database.TaskStatusTodo, database.TaskStatusInProgress}).
+		Group("assignee_id, status").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count open tasks"})
+		return
+	}
+
+	workloads := make([]UserWorkload, len(users))
+	byID := make(map[uint]*UserWorkload, len(users))
+	for i, user := range users {
+		workloads[i] = UserWorkload{UserID: user.ID, Username: user.Username, DisplayName: user.DisplayName}
+		byID[user.ID] = &workloads[i]
+	}
+	for _, row := range rows {
+		workload := byID[*row.AssigneeID]
+		if workload == nil {
+			// Assigned to a deleted user.
+			continue
+		}
+		workload.OpenTasks += row.Count
+		if row.Status == database.TaskStatusTodo {
+			workload.TodoTasks += row.Count
+		} else {
+			workload.InProgressTasks += row.Count
+		}
+	}
+
+	load := make(map[uint]int64, len(workloads))
+	var overloaded []*UserWorkload
+	for i := range workloads {
+		load[workloads[i].UserID] = workloads[i].OpenTasks
+		if workloads[i].OpenTasks > threshold {
+			workloads[i].Overloaded = true
+			overloaded = append(overloaded, &workloads[i])
+		}
+	}
+	sort.SliceStable(overloaded, func(i, j int) bool { return overloaded[i].OpenTasks > overloaded[j].OpenTasks })
+
+	// leastLoaded returns the user with the fewest open tasks other than
+	// the one being relieved, preferring lower IDs on ties.
+	leastLoaded := func(exclude uint) *UserWorkload {
+		var best *UserWorkload
+		for i := range workloads {
+			candidate := &workloads[i]
+			if candidate.UserID != exclude && (best == nil || load[candidate.UserID] < load[best.UserID]) {
+				best = candidate
+			}
+		}
+		return best
+	}
+
+	suggestions := []ReassignmentSuggestion{}
+	for _, from := range overloaded {
+		excess := load[from.UserID] - threshold
+		if excess > from.TodoTasks {
+			excess = from.TodoTasks
+		}
+
+		// The newest todo tasks are the least likely to have been started
+		// on informally, so they are the first to move.
+		var candidates []Task
+		if err := dbFor(c).
+			Select("id", "title").
+			Where("assignee_id = ? AND status = ?", from.UserID, database.TaskStatusTodo).
+			Order("created_at desc, id desc").
+			Limit(int(excess)).
+			Find(&candidates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+			return
+		}
+
+		for _, task := range candidates {
+			to := leastLoaded(from.UserID)
+			if to == nil || load[to.UserID]+1 > threshold {
+				break
+			}
+			suggestions = append(suggestions, ReassignmentSuggestion{
+				TaskID:       task.ID,
+				Title:        task.Title,
+				FromUserID:   from.UserID,
+				FromUsername: from.Username,
+				ToUserID:     to.UserID,
+				ToUsername:   to.Username,
+			})
+			load[from.UserID]--
+			load[to.UserID]++
+		}
+	}
+
+	c.JSON(http.StatusOK, WorkloadResponse{
+		Threshold:   threshold,
+		Users:       workloads,
+		Suggestions: suggestions,
+		GeneratedAt: time.Now(),
+	})
+}
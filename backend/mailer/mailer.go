@@ -0,0 +1,150 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"ziggler_backend/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+
+	// UnsubscribeURL is sent as the List-Unsubscribe header when set, with
+	// List-Unsubscribe-Post so mail clients can offer one-click unsubscribe
+	// (RFC 8058) by posting to it.
+	UnsubscribeURL string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPMailer delivers mail through an SMTP server. Authentication is only
+// attempted when a username is configured, so local sinks such as MailHog
+// work without credentials.
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+	Host     string
+}
+
+// Send delivers msg as smtp.SendMail does, using STARTTLS when the server
+// offers it, but gives up when ctx is done.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMessage(m.From, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// LogMailer only logs outgoing mail. It is used when no SMTP host is
+// configured.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	slog.Info("Email not sent, SMTP not configured", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// buildMessage formats msg for SMTP. Header values can carry user input,
+// such as a task title in the subject, so line breaks are removed from all
+// of them to keep them from adding headers, and the subject is RFC 2047
+// encoded when it is not plain ASCII.
+func buildMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", headerValue(msg.Subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	if msg.UnsubscribeURL != "" {
+		fmt.Fprintf(&b, "List-Unsubscribe: <%s>\r\n", headerValue(msg.UnsubscribeURL))
+		b.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue joins the lines of s into one, collapsing whitespace.
+func headerValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var Default Mailer = LogMailer{}
+
+// Init selects the mailer from the SMTP settings in config.
+func Init() {
+	cfg := config.AppConfig
+	if cfg.SMTPHost != "" {
+		Default = &SMTPMailer{
+			Addr:     net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+			From:     cfg.SMTPFrom,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			Host:     cfg.SMTPHost,
+		}
+		slog.Info("Mailer using SMTP server", "host", cfg.SMTPHost, "port", cfg.SMTPPort)
+	} else {
+		slog.Info("SMTP_HOST not set, emails will only be logged")
+	}
+}
+
+// Send delivers msg with the configured mailer. Callers retry on error;
+// there is no queue, so a message that was not sent is never reported as
+// sent.
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
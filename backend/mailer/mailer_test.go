@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"testing"
+)
+
+func TestBuildMessageKeepsHeadersOnOneLine(t *testing.T) {
+	msg := Message{
+		To:             "bob@example.com\r\nCc: eve@example.com",
+		Subject:        "You were assigned \"Fix\r\nBcc: eve@example.com\r\n\r\nInjected body\"",
+		Body:           "Hi Bob,\n\nHello.\n",
+		UnsubscribeURL: "http://localhost/unsubscribe?token=abc\nX-Evil: 1",
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(buildMessage("ziggler@example.com", msg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Bcc", "Cc", "X-Evil"} {
+		if value := parsed.Header.Get(name); value != "" {
+			t.Errorf("message has injected header %s: %q", name, value)
+		}
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `You were assigned "Fix Bcc: eve@example.com Injected body"`; subject != want {
+		t.Errorf("subject = %q, want %q", subject, want)
+	}
+}
+
+func TestBuildMessageEncodesNonASCIISubject(t *testing.T) {
+	raw := buildMessage("ziggler@example.com", Message{To: "bob@example.com", Subject: "Tâche assignée", Body: "Hi"})
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := parsed.Header.Get("Subject")
+	if header == "Tâche assignée" {
+		t.Fatalf("subject header %q is not RFC 2047 encoded", header)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(header)
+	if err != nil || subject != "Tâche assignée" {
+		t.Errorf("decoded subject = %q, %v; want Tâche assignée", subject, err)
+	}
+}
+
+func TestBuildMessageOffersOneClickUnsubscribe(t *testing.T) {
+	raw := buildMessage("ziggler@example.com", Message{To: "bob@example.com", Subject: "Hi", Body: "Hi", UnsubscribeURL: "http://localhost/unsubscribe?token=abc"})
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<http://localhost/unsubscribe?token=abc>" {
+		t.Errorf("List-Unsubscribe = %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q, want List-Unsubscribe=One-Click", got)
+	}
+}
@@ -1,60 +1,49 @@
 package main
 
 import (
-	"ziggler_backend/config"
-	"ziggler_backend/database"
-	"ziggler_backend/handlers"
-	"ziggler_backend/middleware"
-
-	"github.com/gin-gonic/gin"
+	"fmt"
+	"os"
 )
 
-func main() {
-	config.LoadConfig()
-
-	gin.SetMode(config.AppConfig.GinMode)
-
-	database.InitDB()
-
-	database.SeedDatabase()
-
-	handlers.InitWebSocket()
-
-	r := gin.Default()
-
-	r.GET("/api/v1/ws", handlers.WebSocketHandler)
-
-	r.Use(middleware.CorsMiddleware())
-
-	api := r.Group("/api/v1")
-
-	api.GET("/health", handlers.HealthCheck)
-
-	api.POST("/auth/register", handlers.Register)
-	api.POST("/auth/login", handlers.Login)
-
-	protected := api.Group("/")
-	protected.Use(middleware.JWTAuthMiddleware())
-	{
-
-		protected.GET("/profile", handlers.GetProfile)
-
-		protected.GET("/tasks", handlers.GetTasks)
-		protected.POST("/tasks", handlers.CreateTask)
-		protected.GET("/tasks/:id", handlers.GetTask)
-		protected.PUT("/tasks/:id", handlers.UpdateTask)
-		protected.DELETE("/tasks/:id", handlers.DeleteTask)
-		protected.GET("/tasks/:id/subtasks", handlers.GetSubtasks)
-
-		protected.GET("/users", handlers.GetUsers)
-		protected.POST("/users", handlers.CreateUser)
-		protected.GET("/users/:id", handlers.GetUser)
-		protected.PUT("/users/:id", handlers.UpdateUser)
-		protected.DELETE("/users/:id", handlers.DeleteUser)
+const usage = `usage: ziggler_backend [command]
+
+commands:
+  serve               run the API server (the default)
+  migrate <command>   apply, revert or list schema migrations
+  user <command>      create users and reset passwords
+  seed                add the sample users and tasks to an empty database
+  export <file>       write the database to a JSON file
+  import <file>       load a file written by export into an empty database
+
+Every command reads the same environment variables as the server.`
+
+// commands maps each subcommand to a function that runs it and returns the
+// exit code.
+var commands = map[string]func(args []string) int{
+	"serve":   runServe,
+	"migrate": runMigrate,
+	"user":    runUser,
+	"seed":    runSeed,
+	"export":  runExport,
+	"import":  runImport,
+}
 
-		protected.GET("/stats", handlers.GetStats)
+func main() {
+	name, args := "serve", []string(nil)
+	if len(os.Args) > 1 {
+		name, args = os.Args[1], os.Args[2:]
+	}
 
+	switch name {
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+		return
 	}
 
-	r.Run(":" + config.AppConfig.Port)
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", name, usage)
+		os.Exit(2)
+	}
+	os.Exit(run(args))
 }
@@ -6,6 +6,7 @@ import (
 
 	"ziggler_backend/auth"
 	"ziggler_backend/config"
+	"ziggler_backend/logging"
 
 	"github.com/gin-gonic/gin"
 )
@@ -61,7 +62,23 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
+		c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), claims.UserID))
 
 		c.Next()
 	})
 }
+
+// StreamAuthMiddleware behaves like JWTAuthMiddleware but also accepts the
+// token in the access_token query parameter, since EventSource cannot set
+// request headers.
+func StreamAuthMiddleware() gin.HandlerFunc {
+	jwtAuth := JWTAuthMiddleware()
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		jwtAuth(c)
+	})
+}
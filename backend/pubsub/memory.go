@@ -0,0 +1,77 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errClosed = errors.New("pubsub: closed")
+
+// Memory is a process-local PubSub for single instance deployments.
+type Memory struct {
+	mu          sync.Mutex
+	subscribers map[chan []byte]bool
+	closed      bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[chan []byte]bool)}
+}
+
+func (m *Memory) Publish(ctx context.Context, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return errClosed
+	}
+
+	for sub := range m.subscribers {
+		select {
+		case sub <- data:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, errClosed
+	}
+
+	sub := make(chan []byte, 100)
+	m.subscribers[sub] = true
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.subscribers[sub] {
+			delete(m.subscribers, sub)
+			close(sub)
+		}
+	}()
+
+	return sub, nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for sub := range m.subscribers {
+		delete(m.subscribers, sub)
+		close(sub)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"ziggler_backend/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Presence shares what each replica knows about connected users with the
+// other replicas. Every replica saves a snapshot of its own connections,
+// which expires unless it is saved again within the TTL, so the snapshot of
+// a replica that died disappears on its own.
+type Presence interface {
+	// Save replaces the snapshot of replica.
+	Save(ctx context.Context, replica string, snapshot []byte, ttl time.Duration) error
+	// Load returns the snapshot of every live replica, keyed by replica.
+	Load(ctx context.Context) (map[string][]byte, error)
+	// Remove drops the snapshot of replica.
+	Remove(ctx context.Context, replica string) error
+	Close() error
+}
+
+// NewPresence returns the presence store matching PUBSUB_DRIVER.
+func NewPresence() (Presence, error) {
+	switch config.AppConfig.PubSubDriver {
+	case "", "memory":
+		return NewMemoryPresence(), nil
+	case "redis":
+		return NewRedisPresence(config.AppConfig.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown pub/sub driver %q", config.AppConfig.PubSubDriver)
+	}
+}
+
+type memorySnapshot struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// MemoryPresence keeps the snapshots in process, for single instance
+// deployments.
+type MemoryPresence struct {
+	mu        sync.Mutex
+	snapshots map[string]memorySnapshot
+}
+
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{snapshots: make(map[string]memorySnapshot)}
+}
+
+func (m *MemoryPresence) Save(ctx context.Context, replica string, snapshot []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.snapshots[replica] = memorySnapshot{data: snapshot, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryPresence) Load(ctx context.Context) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	snapshots := make(map[string][]byte, len(m.snapshots))
+	for replica, snapshot := range m.snapshots {
+		if now.After(snapshot.expiresAt) {
+			delete(m.snapshots, replica)
+			continue
+		}
+		snapshots[replica] = snapshot.data
+	}
+	return snapshots, nil
+}
+
+func (m *MemoryPresence) Remove(ctx context.Context, replica string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.snapshots, replica)
+	return nil
+}
+
+func (m *MemoryPresence) Close() error {
+	return nil
+}
+
+const (
+	presenceKeyPrefix = "ziggler:presence:"
+	// presenceReplicasKey is a sorted set of replicas scored by the time
+	// their snapshot expires, so readers know which keys to fetch.
+	presenceReplicasKey = "ziggler:presence-replicas"
+)
+
+// RedisPresence stores each replica's snapshot under its own key with a
+// TTL.
+type RedisPresence struct {
+	client *redis.Client
+}
+
+func NewRedisPresence(url string) (*RedisPresence, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisPresence{client: client}, nil
+}
+
+func (r *RedisPresence) Save(ctx context.Context, replica string, snapshot []byte, ttl time.Duration) error {
+	expiresAt := time.Now().Add(ttl)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, presenceKeyPrefix+replica, snapshot, ttl)
+		pipe.ZAdd(ctx, presenceReplicasKey, redis.Z{Score: float64(expiresAt.Unix()), Member: replica})
+		return nil
+	})
+	return err
+}
+
+func (r *RedisPresence) Load(ctx context.Context) (map[string][]byte, error) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := r.client.ZRemRangeByScore(ctx, presenceReplicasKey, "-inf", "("+now).Err(); err != nil {
+		return nil, err
+	}
+	replicas, err := r.client.ZRange(ctx, presenceReplicasKey, 0, -1).Result()
+	if err != nil || len(replicas) == 0 {
+		return map[string][]byte{}, err
+	}
+
+	keys := make([]string, len(replicas))
+	for i, replica := range replicas {
+		keys[i] = presenceKeyPrefix + replica
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make(map[string][]byte, len(replicas))
+	for i, value := range values {
+		// The key expired before the replica was pruned from the set.
+		if data, ok := value.(string); ok {
+			snapshots[replicas[i]] = []byte(data)
+		}
+	}
+	return snapshots, nil
+}
+
+func (r *RedisPresence) Remove(ctx context.Context, replica string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, presenceKeyPrefix+replica)
+		pipe.ZRem(ctx, presenceReplicasKey, replica)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisPresence) Close() error {
+	return r.client.Close()
+}
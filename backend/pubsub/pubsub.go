@@ -0,0 +1,34 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"ziggler_backend/config"
+)
+
+// Channel is the broker channel every replica publishes hub events to.
+const Channel = "ziggler:events"
+
+// PubSub relays hub events between backend replicas. Every message
+// published, including by this process, is delivered to every subscriber.
+type PubSub interface {
+	Publish(ctx context.Context, data []byte) error
+	Subscribe(ctx context.Context) (<-chan []byte, error)
+	Close() error
+}
+
+// New returns the backend selected by PUBSUB_DRIVER.
+func New() (PubSub, error) {
+	switch config.AppConfig.PubSubDriver {
+	case "", "memory":
+		slog.Info("Using in-memory pub/sub")
+		return NewMemory(), nil
+	case "redis":
+		slog.Info("Using Redis pub/sub")
+		return NewRedis(config.AppConfig.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown pub/sub driver %q", config.AppConfig.PubSubDriver)
+	}
+}
@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis relays events through a Redis PUBLISH/SUBSCRIBE channel so that
+// every replica sharing the Redis instance sees every event.
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &Redis{client: client}, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, data []byte) error {
+	return r.client.Publish(ctx, Channel, data).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	sub := r.client.Subscribe(ctx, Channel)
+	// Wait for the subscription to be confirmed so no event published after
+	// Subscribe returns is missed.
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	out := make(chan []byte, 100)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			select {
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	slog.Info("Subscribed to Redis channel", "channel", Channel)
+	return out, nil
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
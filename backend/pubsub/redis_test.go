@@ -0,0 +1,161 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t *testing.T, server *miniredis.Miniredis) *Redis {
+	t.Helper()
+	r, err := NewRedis("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+// expectMessage fails the test unless want arrives on messages within 5
+// seconds.
+func expectMessage(t *testing.T, messages <-chan []byte, want string) {
+	t.Helper()
+	select {
+	case got, ok := <-messages:
+		if !ok {
+			t.Fatalf("subscription closed waiting for %q", want)
+		}
+		if string(got) != want {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+func TestRedisDeliversToEverySubscriber(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two replicas sharing the Redis instance, each subscribed.
+	a := newTestRedis(t, server)
+	b := newTestRedis(t, server)
+	fromA, err := a.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromB, err := b.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Publish(ctx, []byte("event")); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, fromA, "event")
+	expectMessage(t, fromB, "event")
+}
+
+func TestRedisSubscriptionEndsWithContext(t *testing.T) {
+	r := newTestRedis(t, miniredis.RunT(t))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	messages, err := r.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Fatal("received a message after cancelling")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription still open after cancelling")
+	}
+}
+
+func TestRedisResubscribesAfterReconnect(t *testing.T) {
+	server := miniredis.RunT(t)
+	r := newTestRedis(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, err := r.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events published before the subscription is restored are lost, so
+	// keep publishing until one arrives.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := r.Publish(ctx, []byte("after restart")); err == nil {
+			select {
+			case got, ok := <-messages:
+				if !ok {
+					t.Fatal("subscription closed by the reconnect")
+				}
+				if string(got) != "after restart" {
+					t.Fatalf("received %q, want %q", got, "after restart")
+				}
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no event received after Redis restarted")
+		}
+	}
+}
+
+func TestRedisPresence(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	presence, err := NewRedisPresence("redis://" + server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer presence.Close()
+
+	if err := presence.Save(ctx, "replica-a", []byte("a"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := presence.Save(ctx, "replica-b", []byte("b"), 2*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := presence.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || string(snapshots["replica-a"]) != "a" || string(snapshots["replica-b"]) != "b" {
+		t.Fatalf("snapshots = %q, want both replicas", snapshots)
+	}
+
+	// replica-a stops refreshing its snapshot, so it expires.
+	server.FastForward(90 * time.Second)
+	snapshots, err = presence.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || string(snapshots["replica-b"]) != "b" {
+		t.Fatalf("snapshots after replica-a expired = %q, want only replica-b", snapshots)
+	}
+
+	if err := presence.Remove(ctx, "replica-b"); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, err = presence.Load(ctx); err != nil || len(snapshots) != 0 {
+		t.Fatalf("snapshots after removing replica-b = %q, %v; want none", snapshots, err)
+	}
+}
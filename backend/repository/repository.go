@@ -0,0 +1,20 @@
+// Package repository holds the database access for tasks and users behind
+// interfaces, so the code using them can run against fakes.
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when the requested row does not exist or has been
+// soft-deleted.
+var ErrNotFound = errors.New("not found")
+
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
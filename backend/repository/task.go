@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"ziggler_backend/database"
+
+	"gorm.io/gorm"
+)
+
+// TaskListOptions selects a page of tasks. SortBy must be a task column and
+// SortOrder "asc" or "desc"; callers validate both.
+type TaskListOptions struct {
+	AssigneeID *uint
+	SortBy     string
+	SortOrder  string
+	Offset     int
+	Limit      int
+}
+
+// TaskRepository reads and writes tasks. Soft-deleted tasks are never
+// returned. The write methods run the repository's TaskHooks for actorID in
+// the transaction that writes the task.
+type TaskRepository interface {
+	Get(ctx context.Context, id uint) (*database.Task, error)
+	GetWithRelations(ctx context.Context, id uint) (*database.Task, error)
+	List(ctx context.Context, opts TaskListOptions) ([]database.Task, int64, error)
+	Subtasks(ctx context.Context, parentID uint) ([]database.Task, error)
+	CountSubtasks(ctx context.Context, parentID uint) (int64, error)
+	Create(ctx context.Context, actorID uint, task *database.Task) error
+	Update(ctx context.Context, actorID uint, before database.Task, task *database.Task) error
+	Delete(ctx context.Context, actorID uint, task *database.Task) error
+}
+
+// TaskHooks records what follows from a task write, such as status history,
+// watchers, notifications and outbox events, in the transaction that writes
+// the task, so they commit or roll back with it. TaskCreated and TaskUpdated
+// reload the task with its relations.
+type TaskHooks interface {
+	TaskCreated(tx *gorm.DB, actorID uint, task *database.Task) error
+	TaskUpdated(tx *gorm.DB, actorID uint, before database.Task, task *database.Task) error
+	TaskDeleted(tx *gorm.DB, actorID uint, task database.Task) error
+}
+
+// Sorting by these columns puts tasks without a value first ascending and
+// last descending, whatever the database.
+var nullableSortFields = map[string]bool{
+	"assignee_id": true,
+	"due_date":    true,
+}
+
+// PublicUserFields limits a preloaded user to what other users may see.
+func PublicUserFields(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "username", "display_name", "role")
+}
+
+// NewTaskRepository returns a TaskRepository backed by db that runs hooks
+// in its write transactions.
+func NewTaskRepository(db *gorm.DB, hooks TaskHooks) TaskRepository {
+	return &gormTaskRepository{db: db, hooks: hooks}
+}
+
+type gormTaskRepository struct {
+	db    *gorm.DB
+	hooks TaskHooks
+}
+
+func (r *gormTaskRepository) Get(ctx context.Context, id uint) (*database.Task, error) {
+	var task database.Task
+	if err := r.db.WithContext(ctx).First(&task, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &task, nil
+}
+
+func (r *gormTaskRepository) GetWithRelations(ctx context.Context, id uint) (*database.Task, error) {
+	var task database.Task
+	err := r.db.WithContext(ctx).
+		Preload("Creator", PublicUserFields).Preload("Assignee", PublicUserFields).Preload("Subtasks").Preload("Watchers", PublicUserFields).
+		First(&task, id).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	return &task, nil
+}
+
+func (r *gormTaskRepository) List(ctx context.Context, opts TaskListOptions) ([]database.Task, int64, error) {
+	query := r.db.WithContext(ctx).Model(&database.Task{})
+	if opts.AssigneeID != nil {
+		query = query.Where("assignee_id = ?", *opts.AssigneeID)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := opts.SortBy + " " + opts.SortOrder
+	if nullableSortFields[opts.SortBy] {
+		// SQLite and MySQL sort NULLs first and Postgres last.
+		nullsOrder := "desc"
+		if opts.SortOrder == "desc" {
+			nullsOrder = "asc"
+		}
+		order = opts.SortBy + " IS NULL " + nullsOrder + ", " + order
+	}
+
+	var tasks []database.Task
+	err := query.Preload("Creator", PublicUserFields).Preload("Assignee", PublicUserFields).Preload("Subtasks").
+		Order(order).Offset(opts.Offset).Limit(opts.Limit).
+		Find(&tasks).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+func (r *gormTaskRepository) Subtasks(ctx context.Context, parentID uint) ([]database.Task, error) {
+	var subtasks []database.Task
+	if err := r.db.WithContext(ctx).Where("parent_id = ?", parentID).Find(&subtasks).Error; err != nil {
+		return nil, err
+	}
+	return subtasks, nil
+}
+
+func (r *gormTaskRepository) CountSubtasks(ctx context.Context, parentID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&database.Task{}).Where("parent_id = ?", parentID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormTaskRepository) Create(ctx context.Context, actorID uint, task *database.Task) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		return r.hooks.TaskCreated(tx, actorID, task)
+	})
+}
+
+func (r *gormTaskRepository) Update(ctx context.Context, actorID uint, before database.Task, task *database.Task) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(task).Error; err != nil {
+			return err
+		}
+		return r.hooks.TaskUpdated(tx, actorID, before, task)
+	})
+}
+
+// Delete soft-deletes the task.
+func (r *gormTaskRepository) Delete(ctx context.Context, actorID uint, task *database.Task) error {
+	task.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(task).Error; err != nil {
+			return err
+		}
+		return r.hooks.TaskDeleted(tx, actorID, *task)
+	})
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"ziggler_backend/database"
+
+	"gorm.io/gorm"
+)
+
+// UserRepository reads and writes users.
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (*database.User, error)
+	GetByEmail(ctx context.Context, email string) (*database.User, error)
+	List(ctx context.Context) ([]database.User, error)
+	Create(ctx context.Context, user *database.User) error
+	Save(ctx context.Context, user *database.User) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// NewUserRepository returns a UserRepository backed by db.
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) Get(ctx context.Context, id uint) (*database.User, error) {
+	var user database.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) GetByEmail(ctx context.Context, email string) (*database.User, error) {
+	var user database.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) List(ctx context.Context) ([]database.User, error) {
+	var users []database.User
+	if err := r.db.WithContext(ctx).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *database.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *gormUserRepository) Save(ctx context.Context, user *database.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
+}
+
+func (r *gormUserRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&database.User{}, id).Error
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"ziggler_backend/config"
+	"ziggler_backend/database"
+	"ziggler_backend/handlers"
+	"ziggler_backend/logging"
+	"ziggler_backend/mailer"
+	"ziggler_backend/pubsub"
+	"ziggler_backend/repository"
+	"ziggler_backend/service"
+	"ziggler_backend/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runServe runs the API server until SIGINT or SIGTERM.
+func runServe(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: ziggler_backend serve")
+		return 2
+	}
+
+	config.LoadConfig()
+
+	gin.SetMode(config.AppConfig.GinMode)
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		slog.Debug("Route registered", "method", method, "path", path, "handler", handler)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		logging.Fatal("Failed to initialize tracing", "error", err)
+	}
+
+	database.InitDB()
+
+	if config.AppConfig.SeedSampleData {
+		database.SeedDatabase()
+	}
+
+	users := repository.NewUserRepository(database.DB)
+	tasks := service.NewTaskService(repository.NewTaskRepository(database.DB, handlers.NewTaskHooks()), users)
+
+	ps, err := pubsub.New()
+	if err != nil {
+		logging.Fatal("Failed to initialize pub/sub", "error", err)
+	}
+	presence, err := pubsub.NewPresence()
+	if err != nil {
+		logging.Fatal("Failed to initialize presence store", "error", err)
+	}
+	if err := handlers.InitWebSocket(ps, presence); err != nil {
+		logging.Fatal("Failed to initialize WebSocket hub", "error", err)
+	}
+
+	mailer.Init()
+	handlers.StartEmailNotifications()
+
+	handlers.StartOutboxDispatcher()
+
+	handlers.RegisterMetrics()
+
+	r := handlers.NewRouter(users, tasks)
+
+	srv := &http.Server{
+		Addr:              ":" + config.AppConfig.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	srv.RegisterOnShutdown(handlers.DisconnectClients)
+
+	go func() {
+		slog.Info("Server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Server failed", "error", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	shutdown(srv, shutdownTracing)
+	return 0
+}
+
+// shutdown stops the server in dependency order: no new requests, in-flight
+// requests drained, WebSocket and SSE clients closed, background workers
+// stopped, and finally the database and tracer flushed and closed. All of
+// it shares one SHUTDOWN_TIMEOUT deadline.
+func shutdown(srv *http.Server, shutdownTracing func(context.Context) error) {
+	timeout := time.Duration(config.AppConfig.ShutdownTimeout) * time.Second
+	slog.Info("Shutting down", "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("HTTP server shutdown error", "error", err)
+	}
+
+	handlers.StopEmailNotifications()
+
+	if err := handlers.ShutdownWebSocket(ctx); err != nil {
+		slog.Error("WebSocket shutdown error", "error", err)
+	}
+
+	if err := database.Close(); err != nil {
+		slog.Error("Database close error", "error", err)
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Tracing shutdown error", "error", err)
+	}
+
+	slog.Info("Server stopped")
+}
@@ -0,0 +1,304 @@
+// Package service holds the task rules: validation, the task hierarchy and
+// pagination. It reaches the database only through the repository
+// interfaces.
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"ziggler_backend/database"
+	"ziggler_backend/repository"
+)
+
+var (
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrParentNotFound   = errors.New("parent task not found")
+	ErrAssigneeNotFound = errors.New("assignee not found")
+	ErrOwnParent        = errors.New("task cannot be its own parent")
+	ErrCircularParent   = errors.New("circular dependency detected")
+	ErrHasSubtasks      = errors.New("cannot delete task with subtasks")
+	ErrTitleRequired    = errors.New("title is required")
+	ErrInvalidStatus    = errors.New("invalid status")
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+var sortFields = map[string]bool{
+	"id":          true,
+	"title":       true,
+	"status":      true,
+	"created_at":  true,
+	"updated_at":  true,
+	"creator_id":  true,
+	"assignee_id": true,
+	"due_date":    true,
+}
+
+var statuses = map[string]bool{
+	database.TaskStatusTodo:       true,
+	database.TaskStatusInProgress: true,
+	database.TaskStatusDone:       true,
+	database.TaskStatusCancelled:  true,
+}
+
+// TaskQuery selects a page of tasks. Out of range values fall back to the
+// defaults: page 1, 50 tasks a page, newest first.
+type TaskQuery struct {
+	Page       int
+	PageSize   int
+	SortBy     string
+	SortOrder  string
+	AssigneeID *uint
+}
+
+type TaskPage struct {
+	Tasks      []database.Task
+	Total      int64
+	Page       int
+	PageSize   int
+	TotalPages int
+}
+
+// TaskUpdate holds the fields of a task to change. Nil fields are left
+// alone; a ParentID of 0 moves the task to the top level.
+type TaskUpdate struct {
+	Title        *string    `json:"title,omitempty"`
+	Description  *string    `json:"description,omitempty"`
+	Status       *string    `json:"status,omitempty"`
+	AssigneeID   *uint      `json:"assignee_id,omitempty"`
+	ParentID     *uint      `json:"parent_id,omitempty"`
+	DueDate      *time.Time `json:"due_date,omitempty"`
+	Unassigned   bool       `json:"unassigned,omitempty"`
+	ClearDueDate bool       `json:"clear_due_date,omitempty"`
+}
+
+type TaskService struct {
+	tasks repository.TaskRepository
+	users repository.UserRepository
+}
+
+func NewTaskService(tasks repository.TaskRepository, users repository.UserRepository) *TaskService {
+	return &TaskService{tasks: tasks, users: users}
+}
+
+func (s *TaskService) List(ctx context.Context, query TaskQuery) (TaskPage, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 || query.PageSize > maxPageSize {
+		query.PageSize = defaultPageSize
+	}
+	if !sortFields[query.SortBy] {
+		query.SortBy = "created_at"
+	}
+	if query.SortOrder != "asc" && query.SortOrder != "desc" {
+		query.SortOrder = "desc"
+	}
+
+	tasks, total, err := s.tasks.List(ctx, repository.TaskListOptions{
+		AssigneeID: query.AssigneeID,
+		SortBy:     query.SortBy,
+		SortOrder:  query.SortOrder,
+		Offset:     (query.Page - 1) * query.PageSize,
+		Limit:      query.PageSize,
+	})
+	if err != nil {
+		return TaskPage{}, err
+	}
+
+	return TaskPage{
+		Tasks:      tasks,
+		Total:      total,
+		Page:       query.Page,
+		PageSize:   query.PageSize,
+		TotalPages: int((total + int64(query.PageSize) - 1) / int64(query.PageSize)),
+	}, nil
+}
+
+// Get returns the task with its creator, assignee, subtasks and watchers.
+func (s *TaskService) Get(ctx context.Context, id uint) (*database.Task, error) {
+	task, err := s.tasks.GetWithRelations(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTaskNotFound
+	}
+	return task, err
+}
+
+func (s *TaskService) Subtasks(ctx context.Context, parentID uint) ([]database.Task, error) {
+	return s.tasks.Subtasks(ctx, parentID)
+}
+
+// Create validates and stores a new task created by actorID. Its status
+// defaults to todo.
+func (s *TaskService) Create(ctx context.Context, actorID uint, task *database.Task) error {
+	task.ID = 0
+	task.CreatorID = actorID
+	if task.Status == "" {
+		task.Status = database.TaskStatusTodo
+	}
+	if task.ParentID != nil && *task.ParentID == 0 {
+		task.ParentID = nil
+	}
+	if err := s.validate(ctx, task, nil); err != nil {
+		return err
+	}
+	if task.ParentID != nil {
+		if err := s.checkParent(ctx, 0, *task.ParentID); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	task.CreatedAt = now
+	task.UpdatedAt = now
+	task.StartedAt = nil
+	task.CompletedAt = nil
+	task.MarkStatusReached(now)
+
+	return s.tasks.Create(ctx, actorID, task)
+}
+
+// Update applies update to the task on behalf of actorID and returns the
+// stored task.
+func (s *TaskService) Update(ctx context.Context, actorID, id uint, update TaskUpdate) (*database.Task, error) {
+	task, err := s.tasks.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	before := *task
+
+	if update.Title != nil {
+		task.Title = *update.Title
+	}
+	if update.Description != nil {
+		task.Description = *update.Description
+	}
+	if update.Status != nil {
+		task.Status = *update.Status
+	}
+	if update.AssigneeID != nil {
+		task.AssigneeID = update.AssigneeID
+	}
+	if update.Unassigned {
+		task.AssigneeID = nil
+	}
+	if update.DueDate != nil {
+		task.DueDate = update.DueDate
+	}
+	if update.ClearDueDate {
+		task.DueDate = nil
+	}
+	if update.ParentID != nil {
+		if *update.ParentID == 0 {
+			task.ParentID = nil
+		} else {
+			if err := s.checkParent(ctx, task.ID, *update.ParentID); err != nil {
+				return nil, err
+			}
+			task.ParentID = update.ParentID
+		}
+	}
+	if err := s.validate(ctx, task, &before); err != nil {
+		return nil, err
+	}
+
+	task.UpdatedAt = time.Now()
+	task.MarkStatusReached(task.UpdatedAt)
+
+	if err := s.tasks.Update(ctx, actorID, before, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// Delete soft-deletes the task on behalf of actorID. Tasks with subtasks
+// cannot be deleted.
+func (s *TaskService) Delete(ctx context.Context, actorID, id uint) error {
+	task, err := s.tasks.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	subtasks, err := s.tasks.CountSubtasks(ctx, id)
+	if err != nil {
+		return err
+	}
+	if subtasks > 0 {
+		return ErrHasSubtasks
+	}
+
+	return s.tasks.Delete(ctx, actorID, task)
+}
+
+// validate checks the fields of task that differ from before, which is nil
+// for a new task.
+func (s *TaskService) validate(ctx context.Context, task, before *database.Task) error {
+	isNew := before == nil
+	if (isNew || task.Title != before.Title) && strings.TrimSpace(task.Title) == "" {
+		return ErrTitleRequired
+	}
+	if (isNew || task.Status != before.Status) && !statuses[task.Status] {
+		return ErrInvalidStatus
+	}
+	if task.AssigneeID != nil && (isNew || before.AssigneeID == nil || *before.AssigneeID != *task.AssigneeID) {
+		if _, err := s.users.Get(ctx, *task.AssigneeID); errors.Is(err, repository.ErrNotFound) {
+			return ErrAssigneeNotFound
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParent checks that the task with taskID (0 for a new task) can be
+// placed under parentID: the parent must exist and must not be the task or
+// one of its subtasks.
+func (s *TaskService) checkParent(ctx context.Context, taskID, parentID uint) error {
+	if parentID == taskID {
+		return ErrOwnParent
+	}
+	parent, err := s.tasks.Get(ctx, parentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if taskID == 0 {
+		return nil
+	}
+
+	// Walk up from the new parent. Reaching the task means the move would
+	// make it its own ancestor.
+	seen := map[uint]bool{parent.ID: true}
+	for ancestor := parent; ancestor.ParentID != nil; {
+		if *ancestor.ParentID == taskID {
+			return ErrCircularParent
+		}
+		if seen[*ancestor.ParentID] {
+			return nil
+		}
+		seen[*ancestor.ParentID] = true
+
+		ancestor, err = s.tasks.Get(ctx, *ancestor.ParentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
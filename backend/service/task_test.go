@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ziggler_backend/database"
+	"ziggler_backend/repository"
+)
+
+// fakeTasks is an in-memory TaskRepository that counts writes. Get returns
+// copies, so the service cannot change stored tasks without writing them.
+type fakeTasks struct {
+	tasks  map[uint]database.Task
+	nextID uint
+
+	created, updated, deleted int
+}
+
+func newFakeTasks(tasks ...database.Task) *fakeTasks {
+	f := &fakeTasks{tasks: make(map[uint]database.Task), nextID: 1}
+	for _, task := range tasks {
+		f.tasks[task.ID] = task
+		if task.ID >= f.nextID {
+			f.nextID = task.ID + 1
+		}
+	}
+	return f
+}
+
+func (f *fakeTasks) Get(ctx context.Context, id uint) (*database.Task, error) {
+	task, ok := f.tasks[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &task, nil
+}
+
+func (f *fakeTasks) GetWithRelations(ctx context.Context, id uint) (*database.Task, error) {
+	return f.Get(ctx, id)
+}
+
+func (f *fakeTasks) List(ctx context.Context, opts repository.TaskListOptions) ([]database.Task, int64, error) {
+	var tasks []database.Task
+	for _, task := range f.tasks {
+		tasks = append(tasks, task)
+	}
+	return tasks, int64(len(tasks)), nil
+}
+
+func (f *fakeTasks) Subtasks(ctx context.Context, parentID uint) ([]database.Task, error) {
+	var subtasks []database.Task
+	for _, task := range f.tasks {
+		if task.ParentID != nil && *task.ParentID == parentID {
+			subtasks = append(subtasks, task)
+		}
+	}
+	return subtasks, nil
+}
+
+func (f *fakeTasks) CountSubtasks(ctx context.Context, parentID uint) (int64, error) {
+	subtasks, err := f.Subtasks(ctx, parentID)
+	return int64(len(subtasks)), err
+}
+
+func (f *fakeTasks) Create(ctx context.Context, actorID uint, task *database.Task) error {
+	task.ID = f.nextID
+	f.nextID++
+	f.tasks[task.ID] = *task
+	f.created++
+	return nil
+}
+
+func (f *fakeTasks) Update(ctx context.Context, actorID uint, before database.Task, task *database.Task) error {
+	f.tasks[task.ID] = *task
+	f.updated++
+	return nil
+}
+
+func (f *fakeTasks) Delete(ctx context.Context, actorID uint, task *database.Task) error {
+	delete(f.tasks, task.ID)
+	f.deleted++
+	return nil
+}
+
+// fakeUsers is an in-memory UserRepository holding only what the task
+// service reads.
+type fakeUsers struct {
+	repository.UserRepository
+	users map[uint]database.User
+}
+
+func (f fakeUsers) Get(ctx context.Context, id uint) (*database.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &user, nil
+}
+
+func parent(id uint) *uint {
+	return &id
+}
+
+func newTestService(tasks ...database.Task) (*TaskService, *fakeTasks) {
+	users := fakeUsers{users: map[uint]database.User{1: {ID: 1, Username: "alice"}}}
+	repo := newFakeTasks(tasks...)
+	return NewTaskService(repo, users), repo
+}
+
+func TestCheckParent(t *testing.T) {
+	// 1 ─ 2 ─ 3 ─ 4, and 5 on its own.
+	s, _ := newTestService(
+		database.Task{ID: 1},
+		database.Task{ID: 2, ParentID: parent(1)},
+		database.Task{ID: 3, ParentID: parent(2)},
+		database.Task{ID: 4, ParentID: parent(3)},
+		database.Task{ID: 5},
+	)
+
+	for _, tc := range []struct {
+		name             string
+		taskID, parentID uint
+		want             error
+	}{
+		{"own parent", 2, 2, ErrOwnParent},
+		{"missing parent", 2, 99, ErrParentNotFound},
+		{"new task", 0, 4, nil},
+		{"new task with missing parent", 0, 99, ErrParentNotFound},
+		{"direct cycle", 1, 2, ErrCircularParent},
+		{"deep cycle", 1, 4, ErrCircularParent},
+		{"move within the tree", 4, 1, nil},
+		{"move to another tree", 2, 5, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := s.checkParent(context.Background(), tc.taskID, tc.parentID); !errors.Is(err, tc.want) {
+				t.Errorf("checkParent(%d, %d) = %v, want %v", tc.taskID, tc.parentID, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckParentStopsOnExistingLoop(t *testing.T) {
+	// 2 and 3 already point at each other; moving 1 under 2 must still
+	// terminate.
+	s, _ := newTestService(
+		database.Task{ID: 1},
+		database.Task{ID: 2, ParentID: parent(3)},
+		database.Task{ID: 3, ParentID: parent(2)},
+	)
+	if err := s.checkParent(context.Background(), 1, 2); err != nil {
+		t.Errorf("checkParent = %v, want nil", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, _ := newTestService()
+	alice, nobody := uint(1), uint(99)
+
+	for _, tc := range []struct {
+		name   string
+		task   database.Task
+		before *database.Task
+		want   error
+	}{
+		{"valid", database.Task{Title: "Task", Status: database.TaskStatusTodo, AssigneeID: &alice}, nil, nil},
+		{"blank title", database.Task{Title: "  ", Status: database.TaskStatusTodo}, nil, ErrTitleRequired},
+		{"unknown status", database.Task{Title: "Task", Status: "blocked"}, nil, ErrInvalidStatus},
+		{"missing assignee", database.Task{Title: "Task", Status: database.TaskStatusTodo, AssigneeID: &nobody}, nil, ErrAssigneeNotFound},
+		{
+			"unchanged assignee is not checked again",
+			database.Task{Title: "Task", Status: database.TaskStatusTodo, AssigneeID: &nobody, Description: "Changed"},
+			&database.Task{Title: "Task", Status: database.TaskStatusTodo, AssigneeID: &nobody},
+			nil,
+		},
+		{
+			"changed status is checked",
+			database.Task{Title: "Task", Status: "blocked"},
+			&database.Task{Title: "Task", Status: database.TaskStatusTodo},
+			ErrInvalidStatus,
+		},
+		{
+			"unchanged invalid status is kept",
+			database.Task{Title: "Renamed", Status: "blocked"},
+			&database.Task{Title: "Task", Status: "blocked"},
+			nil,
+		},
+		{
+			"new assignee is checked",
+			database.Task{Title: "Task", Status: database.TaskStatusTodo, AssigneeID: &nobody},
+			&database.Task{Title: "Task", Status: database.TaskStatusTodo, AssigneeID: &alice},
+			ErrAssigneeNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := s.validate(context.Background(), &tc.task, tc.before); !errors.Is(err, tc.want) {
+				t.Errorf("validate = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpdateRejectsCycleWithoutWriting(t *testing.T) {
+	s, repo := newTestService(
+		database.Task{ID: 1, Title: "Root", Status: database.TaskStatusTodo},
+		database.Task{ID: 2, Title: "Child", Status: database.TaskStatusTodo, ParentID: parent(1)},
+	)
+
+	if _, err := s.Update(context.Background(), 1, 1, TaskUpdate{ParentID: parent(2)}); !errors.Is(err, ErrCircularParent) {
+		t.Fatalf("Update = %v, want %v", err, ErrCircularParent)
+	}
+	if root, _ := s.tasks.Get(context.Background(), 1); root.ParentID != nil {
+		t.Errorf("root parent = %d, want it unchanged", *root.ParentID)
+	}
+	if repo.updated != 0 {
+		t.Errorf("wrote %d updates, want none", repo.updated)
+	}
+}
@@ -0,0 +1,99 @@
+// Package tracing sets up OpenTelemetry tracing for HTTP requests, database
+// statements and the realtime event path. Tracing is off unless an exporter
+// is configured, in which case spans are sent over OTLP/HTTP.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"ziggler_backend/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "ziggler_backend"
+
+// Init installs the tracer provider selected by OTEL_TRACES_EXPORTER and
+// returns a function that flushes and stops it. With "none" the global
+// no-op provider stays in place and spans cost next to nothing.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	cfg := config.AppConfig
+	switch cfg.OTelExporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		opts := []otlptracehttp.Option{}
+		if strings.Contains(cfg.OTelEndpoint, "://") {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTelEndpoint))
+		} else if cfg.OTelEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.OTelEndpoint))
+		}
+		if cfg.OTelInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		provider := NewProvider(sdktrace.WithBatcher(exporter))
+		otel.SetTracerProvider(provider)
+		slog.Info("Tracing enabled", "exporter", "otlp", "endpoint", cfg.OTelEndpoint)
+		return provider.Shutdown, nil
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", cfg.OTelExporter)
+	}
+}
+
+// NewProvider builds a tracer provider for this service. Tests can pass
+// sdktrace.WithSyncer(tracetest.NewInMemoryExporter()) and install the
+// result with otel.SetTracerProvider to inspect the recorded spans.
+func NewProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	serviceName := "ziggler-backend"
+	if config.AppConfig != nil && config.AppConfig.OTelServiceName != "" {
+		serviceName = config.AppConfig.OTelServiceName
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", serviceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
+
+// Tracer returns the tracer used by the backend's own spans. It always
+// reflects the current global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start begins a span as a child of any span in ctx.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Inject serialises the span context in ctx so it can be stored and the
+// trace continued later, e.g. by the outbox dispatcher.
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Extract restores a span context serialised by Inject.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
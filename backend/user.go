@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"ziggler_backend/auth"
+	"ziggler_backend/config"
+	"ziggler_backend/database"
+
+	"gorm.io/gorm"
+)
+
+const userUsage = `usage: ziggler_backend user <command> [options]
+
+commands:
+  create           create a user
+  reset-password   set a new password for a user
+
+A password that is left out is generated and printed.
+Run "ziggler_backend user <command> -h" for a command's options.`
+
+// Same minimum as registration.
+const minPasswordLength = 6
+
+// runUser runs the user command and returns the exit code.
+func runUser(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "create":
+		return runUserCreate(args[1:])
+	case "reset-password":
+		return runUserResetPassword(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+}
+
+func runUserCreate(args []string) int {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	username := fs.String("username", "", "username (required)")
+	email := fs.String("email", "", "email address used to log in (required)")
+	displayName := fs.String("display-name", "", "display name (defaults to the username)")
+	password := fs.String("password", "", "password (generated if empty)")
+	admin := fs.Bool("admin", false, "give the user the admin role")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *username == "" || *email == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "user create: -username and -email are required")
+		fs.Usage()
+		return 2
+	}
+	if *displayName == "" {
+		*displayName = *username
+	}
+
+	pass, generated, err := choosePassword(*password)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "user create:", err)
+		return 2
+	}
+
+	config.LoadConfig()
+	database.InitDB()
+	defer database.Close()
+
+	// The unique indexes cover soft-deleted users too.
+	var existing int64
+	if err := database.DB.Unscoped().Model(&database.User{}).
+		Where("email = ? OR username = ?", *email, *username).
+		Count(&existing).Error; err != nil {
+		fmt.Fprintln(os.Stderr, "user create:", err)
+		return 1
+	}
+	if existing > 0 {
+		fmt.Fprintln(os.Stderr, "user create: a user with this email or username already exists")
+		return 1
+	}
+
+	hashedPassword, err := auth.HashPassword(pass)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "user create:", err)
+		return 1
+	}
+
+	user := database.User{
+		Username:    *username,
+		Email:       *email,
+		Password:    hashedPassword,
+		Role:        database.RoleUser,
+		DisplayName: *displayName,
+	}
+	if *admin {
+		user.Role = database.RoleAdmin
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		fmt.Fprintln(os.Stderr, "user create:", err)
+		return 1
+	}
+
+	fmt.Printf("created user %s (id %d, role %s)\n", user.Email, user.ID, user.Role)
+	if generated {
+		fmt.Println("password:", pass)
+	}
+	return 0
+}
+
+func runUserResetPassword(args []string) int {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	email := fs.String("email", "", "email address of the user (required)")
+	password := fs.String("password", "", "new password (generated if empty)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *email == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "user reset-password: -email is required")
+		fs.Usage()
+		return 2
+	}
+
+	pass, generated, err := choosePassword(*password)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "user reset-password:", err)
+		return 2
+	}
+
+	config.LoadConfig()
+	database.InitDB()
+	defer database.Close()
+
+	var user database.User
+	if err := database.DB.Where("email = ?", *email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = fmt.Errorf("no user with email %s", *email)
+		}
+		fmt.Fprintln(os.Stderr, "user reset-password:", err)
+		return 1
+	}
+
+	hashedPassword, err := auth.HashPassword(pass)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "user reset-password:", err)
+		return 1
+	}
+	if err := database.DB.Model(&user).Update("password", hashedPassword).Error; err != nil {
+		fmt.Fprintln(os.Stderr, "user reset-password:", err)
+		return 1
+	}
+
+	fmt.Printf("reset password for %s (id %d)\n", user.Email, user.ID)
+	if generated {
+		fmt.Println("password:", pass)
+	}
+	return 0
+}
+
+// choosePassword checks the given password or, if it is empty, generates
+// one. It reports whether the password was generated.
+func choosePassword(password string) (string, bool, error) {
+	if password != "" {
+		if len(password) < minPasswordLength {
+			return "", false, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+		}
+		return password, false, nil
+	}
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), true, nil
+}
@@ -236,7 +236,7 @@ export default function DashboardPage() {
                 const data = JSON.parse(event.data)
 
                 // Handle real-time task updates
-                if (data.type === 'task_created' || data.type === 'task_updated' || data.type === 'task_deleted') {
+                if (data.type === 'task_created' || data.type === 'task_updated' || data.type === 'task_deleted' || data.type === 'resync_required') {
                     // Invalidate and refetch tasks
                     queryClient.invalidateQueries({ queryKey: ['tasks'] })
                 }
@@ -44,8 +44,12 @@ apiClient.interceptors.response.use(
 
 export { apiClient }
 
+// Sequence number of the last event received, used to resume after a reconnect
+let lastEventSeq: number | null = null
+
 export const createWebSocketConnection = (token: string): WebSocket => {
-  const ws = new WebSocket(`${WS_BASE_URL}/api/v1/ws`)
+  const resumeQuery = lastEventSeq !== null ? `?resume_from=${lastEventSeq}` : ''
+  const ws = new WebSocket(`${WS_BASE_URL}/api/v1/ws${resumeQuery}`)
   
   ws.onopen = () => {
     console.log('WebSocket connected')
@@ -60,8 +64,20 @@ export const createWebSocketConnection = (token: string): WebSocket => {
     try {
       const data = JSON.parse(event.data)
 
-      if (data.type === 'authenticated') {
+      if (typeof data.seq === 'number') {
+        lastEventSeq = data.seq
+      }
+
+      if (data.type === 'connected' && lastEventSeq === null) {
+        lastEventSeq = data.payload.latest_seq
+      } else if (data.type === 'resync_required') {
+        lastEventSeq = data.payload.latest_seq
+      } else if (data.type === 'authenticated') {
         console.log('WebSocket authenticated:', data.payload)
+        ws.send(JSON.stringify({
+          type: 'subscribe',
+          topics: ['tasks', 'stats']
+        }))
       } else if (data.type === 'auth_error') {
         console.error('WebSocket authentication error:', data.payload)
         ws.close()
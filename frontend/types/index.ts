@@ -10,10 +10,14 @@ export interface Task {
   creator_id: number
   assignee_id?: number
   parent_id?: number
+  due_date?: string
+  started_at?: string
+  completed_at?: string
   created_at: string
   updated_at: string
   creator?: User
   assignee?: User
+  watchers?: User[]
 }
 
 export interface User {